@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/inkyblackness/res/chunk"
+)
+
+// createResourceFile writes a new resource file without any chunks.
+// An existing file is not overwritten.
+func createResourceFile(rep *reporter, resourceFile string) {
+	if _, statErr := os.Stat(resourceFile); statErr == nil {
+		rep.failure("Resource file %v already exists", resourceFile)
+		return
+	}
+	err := saveResourceFile(resourceFile, chunk.NewProviderBackedStore(emptyProvider{}), saveOptions{})
+	if err != nil {
+		rep.failure("Failed to save file: %v", err)
+	}
+}
+
+// newChunkProperties describes the meta information of a chunk to add.
+type newChunkProperties struct {
+	contentType chunk.ContentType
+	compressed  bool
+	fragmented  bool
+}
+
+// addChunk adds a new chunk to the resource file, with one block per source file.
+// The blocks are converted the same way as on import. If any source file can not be
+// converted, the resource file is left unchanged.
+func addChunk(rep *reporter, resourceFile string, chunkID chunk.Identifier, properties newChunkProperties,
+	sourceFiles []string, options importOptions, save saveOptions) {
+	store, loadErr := loadResourceFile(resourceFile)
+	if loadErr != nil {
+		rep.failure("Failed to read resources from input file: %v", loadErr)
+		return
+	}
+	if hasChunk(store, chunkID) {
+		rep.failure("Chunk %04X already exists", chunkID.Value())
+		return
+	}
+	if !properties.fragmented && (len(sourceFiles) > 1) {
+		rep.failure("Only fragmented chunks can hold more than one block")
+		return
+	}
+	if (properties.contentType == chunk.Text) && (len(sourceFiles) == 1) && isTextFile(sourceFiles[0]) {
+		addText(rep, store, resourceFile, chunkID, properties, sourceFiles[0], options, save)
+		return
+	}
+	blocks := make([][]byte, 0, len(sourceFiles))
+	failed := false
+	for blockID, sourceFile := range sourceFiles {
+		record := newBlockRecord(chunkID, blockID)
+		record.ContentType = contentTypeName(properties.contentType)
+		record.Files = []string{sourceFile}
+		data := importFile(&record, sourceFile, properties.contentType, nil, options)
+		if data != nil {
+			record.Change = fmt.Sprintf("new block with %d bytes", len(data))
+		}
+		failed = failed || (data == nil)
+		blocks = append(blocks, data)
+		rep.block(record)
+	}
+	if failed {
+		return
+	}
+	store.Put(chunkID, &chunk.Chunk{
+		ContentType:   properties.contentType,
+		Compressed:    properties.compressed,
+		Fragmented:    properties.fragmented,
+		BlockProvider: chunk.MemoryBlockProvider(blocks)})
+
+	err := saveResourceFile(resourceFile, store, save)
+	if err != nil {
+		rep.failure("Failed to save file: %v", err)
+	}
+}
+
+// addText adds a new text chunk with all entries of the given source file as blocks.
+func addText(rep *reporter, store *chunk.ProviderBackedStore, resourceFile string, chunkID chunk.Identifier,
+	properties newChunkProperties, sourceFile string, options importOptions, save saveOptions) {
+	newChunk := &chunk.Chunk{
+		ContentType:   properties.contentType,
+		Compressed:    properties.compressed,
+		Fragmented:    properties.fragmented,
+		BlockProvider: chunk.MemoryBlockProvider(nil)}
+	record := newBlockRecord(chunkID, 0)
+	record.ContentType = contentTypeName(properties.contentType)
+	record.Files = []string{sourceFile}
+	imported := importText(&record, store, chunkID, newChunk, sourceFile, options.textLimits.forChunk(chunkID), options.codepage)
+	rep.block(record)
+	if !imported {
+		return
+	}
+	err := saveResourceFile(resourceFile, store, save)
+	if err != nil {
+		rep.failure("Failed to save file: %v", err)
+	}
+}
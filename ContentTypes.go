@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/inkyblackness/res/chunk"
+)
+
+var contentTypeNames = map[chunk.ContentType]string{
+	chunk.Palette:   "palette",
+	chunk.Text:      "text",
+	chunk.Bitmap:    "bitmap",
+	chunk.Font:      "font",
+	chunk.VideoClip: "videoclip",
+	chunk.Sound:     "sound",
+	chunk.Geometry:  "geometry",
+	chunk.Media:     "media",
+	chunk.Map:       "map"}
+
+// contentTypeName returns the readable name of given content type.
+// Unknown types are described by their hexadecimal value.
+func contentTypeName(contentType chunk.ContentType) string {
+	name, known := contentTypeNames[contentType]
+
+	if !known {
+		name = fmt.Sprintf("0x%02X", int(contentType))
+	}
+	return name
+}
+
+// parseContentType resolves either a name or a numerical value to a content type.
+func parseContentType(text string) (contentType chunk.ContentType, err error) {
+	trimmed := strings.ToLower(strings.TrimSpace(text))
+
+	for knownType, name := range contentTypeNames {
+		if name == trimmed {
+			return knownType, nil
+		}
+	}
+	var value uint64
+	value, err = strconv.ParseUint(trimmed, 0, 8)
+	if err != nil {
+		err = fmt.Errorf("unknown content type <%v>", text)
+	}
+	contentType = chunk.ContentType(value)
+	return
+}
+
+// parseContentTypeFilter splits a comma separated list of content types into a set.
+// An empty list results in a nil set, which matches all types.
+func parseContentTypeFilter(text string) (filter map[chunk.ContentType]bool, err error) {
+	if len(text) > 0 {
+		filter = make(map[chunk.ContentType]bool)
+		for _, entry := range strings.Split(text, ",") {
+			contentType, typeErr := parseContentType(entry)
+			if typeErr != nil {
+				return nil, typeErr
+			}
+			filter[contentType] = true
+		}
+	}
+	return
+}
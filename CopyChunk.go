@@ -0,0 +1,93 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/inkyblackness/res/chunk"
+)
+
+// chunkTransfer describes how a chunk is carried over from one resource file to another.
+type chunkTransfer struct {
+	move      bool
+	overwrite bool
+}
+
+// copyChunk copies a chunk byte-exactly from the source resource file into the destination resource file.
+// Content type, compression and fragmentation are kept. The destination can be the same file as the source,
+// in which case the chunk is re-numbered. When moving, the chunk is deleted from the source afterwards.
+func copyChunk(rep *reporter, sourceFile string, sourceID chunk.Identifier,
+	destinationFile string, destinationID chunk.Identifier, transfer chunkTransfer, save saveOptions) {
+	sameFile := isSameFile(sourceFile, destinationFile)
+	if sameFile && (sourceID.Value() == destinationID.Value()) {
+		rep.failure("Source and destination chunk are identical")
+		return
+	}
+	sourceStore, sourceErr := loadResourceFile(sourceFile)
+	if sourceErr != nil {
+		rep.failure("Failed to read resources from source file: %v", sourceErr)
+		return
+	}
+	destinationStore := sourceStore
+	if !sameFile {
+		var destinationErr error
+		destinationStore, destinationErr = loadResourceFile(destinationFile)
+		if destinationErr != nil {
+			rep.failure("Failed to read resources from destination file: %v", destinationErr)
+			return
+		}
+	}
+	replaced := hasChunk(destinationStore, destinationID)
+	if replaced && !transfer.overwrite {
+		rep.failure("Chunk %04X already exists in destination, refusing to overwrite without --overwrite", destinationID.Value())
+		return
+	}
+	sourceChunk, chunkErr := sourceStore.Chunk(sourceID)
+	if chunkErr != nil {
+		rep.failure("Failed to read chunk %04X: %v", sourceID.Value(), chunkErr)
+		return
+	}
+	blocks, readErr := readBlocks(sourceChunk)
+	if readErr != nil {
+		rep.failure("Failed to read blocks of chunk %04X: %v", sourceID.Value(), readErr)
+		return
+	}
+	destinationStore.Put(destinationID, &chunk.Chunk{
+		ContentType:   sourceChunk.ContentType,
+		Compressed:    sourceChunk.Compressed,
+		Fragmented:    sourceChunk.Fragmented,
+		BlockProvider: chunk.MemoryBlockProvider(blocks)})
+	action := "copied"
+	if transfer.move {
+		action = "moved"
+		sourceStore.Del(sourceID)
+		rep.chunkChange(sourceID, sourceChunk.ContentType, "%v to chunk %04X of %v", action, destinationID.Value(), destinationFile)
+	}
+	replacement := ""
+	if replaced {
+		replacement = ", replacing the existing chunk"
+	}
+	rep.chunkChange(destinationID, sourceChunk.ContentType, "%v from chunk %04X of %v with %d blocks%v",
+		action, sourceID.Value(), sourceFile, len(blocks), replacement)
+
+	if err := saveResourceFile(destinationFile, destinationStore, save); err != nil {
+		rep.failure("Failed to save destination file: %v", err)
+		return
+	}
+	// The destination is saved first so that the chunk is never lost. If the source can not be saved
+	// afterwards, the chunk remains in both files.
+	if transfer.move && !sameFile {
+		if err := saveResourceFile(sourceFile, sourceStore, save); err != nil {
+			rep.failure("Failed to save source file, chunk %04X was copied but not deleted from %v: %v",
+				sourceID.Value(), sourceFile, err)
+			if save.backup {
+				rep.warning("The destination file before the copy is kept in %v", destinationFile+".bak")
+			}
+		}
+	}
+}
+
+func isSameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return (errA == nil) && (errB == nil) && (absA == absB)
+}
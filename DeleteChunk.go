@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/inkyblackness/res/chunk"
+)
+
+// blockDeletion describes which blocks of a chunk to delete.
+type blockDeletion struct {
+	blockID  int
+	truncate bool
+	force    bool
+}
+
+// deleteChunk removes the identified chunk from the resource file.
+// If deletion is not nil, only the specified blocks are removed and the chunk is kept.
+func deleteChunk(rep *reporter, resourceFile string, chunkID chunk.Identifier, deletion *blockDeletion, save saveOptions) {
+	store, loadErr := loadResourceFile(resourceFile)
+	if loadErr != nil {
+		rep.failure("Failed to read resources from input file: %v", loadErr)
+		return
+	}
+	if !hasChunk(store, chunkID) {
+		rep.failure("Chunk %04X does not exist", chunkID.Value())
+		return
+	}
+	if deletion == nil {
+		oldChunk, chunkErr := store.Chunk(chunkID)
+		if chunkErr != nil {
+			rep.failure("Failed to access chunk to delete: %v", chunkErr)
+			return
+		}
+		store.Del(chunkID)
+		rep.chunkChange(chunkID, oldChunk.ContentType, "deleted with %d blocks", oldChunk.BlockCount())
+	} else if !deleteBlocks(rep, store, chunkID, *deletion) {
+		return
+	}
+
+	err := saveResourceFile(resourceFile, store, save)
+	if err != nil {
+		rep.failure("Failed to save file: %v", err)
+	}
+}
+
+func deleteBlocks(rep *reporter, store *chunk.ProviderBackedStore, chunkID chunk.Identifier, deletion blockDeletion) bool {
+	record := newBlockRecord(chunkID, deletion.blockID)
+	defer func() { rep.block(record) }()
+
+	modChunk, chunkErr := store.Chunk(chunkID)
+	if chunkErr != nil {
+		record.fail("Failed to access chunk to modify: %v", chunkErr)
+		return false
+	}
+	record.ContentType = contentTypeName(modChunk.ContentType)
+	if !modChunk.Fragmented && !deletion.force {
+		record.fail("Chunk %04X is not fragmented, refusing to delete blocks without --force", chunkID.Value())
+		return false
+	}
+	blocks, readErr := readBlocks(modChunk)
+	if readErr != nil {
+		record.fail("Failed to read blocks: %v", readErr)
+		return false
+	}
+	if (deletion.blockID < 0) || (deletion.blockID >= len(blocks)) {
+		record.fail("Block %d does not exist, chunk has %d blocks", deletion.blockID, len(blocks))
+		return false
+	}
+	oldCount := len(blocks)
+	if deletion.truncate {
+		blocks = blocks[:deletion.blockID]
+	} else {
+		blocks = append(blocks[:deletion.blockID], blocks[deletion.blockID+1:]...)
+	}
+	record.Change = fmt.Sprintf("%d -> %d blocks", oldCount, len(blocks))
+	store.Put(chunkID, &chunk.Chunk{
+		ContentType:   modChunk.ContentType,
+		Compressed:    modChunk.Compressed,
+		Fragmented:    modChunk.Fragmented,
+		BlockProvider: chunk.MemoryBlockProvider(blocks)})
+	return true
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"sort"
+
+	"github.com/inkyblackness/res/chunk"
+	"github.com/inkyblackness/res/image"
+	"github.com/inkyblackness/res/text"
+
+	"github.com/inkyblackness/chunkie/convert"
+)
+
+const (
+	diffAdded    = "added"
+	diffRemoved  = "removed"
+	diffModified = "modified"
+)
+
+var diffSymbols = map[string]string{
+	diffAdded:    "+",
+	diffRemoved:  "-",
+	diffModified: "~"}
+
+type resourceDiff struct {
+	rep  *reporter
+	deep bool
+}
+
+func (diff *resourceDiff) report(chunkID chunk.Identifier, blockID int, change string, format string, a ...interface{}) {
+	record := diffRecord{ChunkID: formatChunkID(chunkID), Change: change, Description: fmt.Sprintf(format, a...)}
+	line := fmt.Sprintf("%s %s", diffSymbols[change], record.ChunkID)
+
+	if blockID >= 0 {
+		record.BlockID = &blockID
+		line += fmt.Sprintf(" block %d", blockID)
+	}
+	diff.rep.entry(&record, line+": "+record.Description)
+}
+
+// diffResources reports the structural differences between two resource files.
+// In deep mode, changed blocks of known content types are decoded to describe the change.
+func diffResources(rep *reporter, fileA string, fileB string, deep bool) {
+	providerA, errA := loadResourceFile(fileA)
+	if errA != nil {
+		rep.failure("Failed to read resources from %v: %v", fileA, errA)
+		return
+	}
+	providerB, errB := loadResourceFile(fileB)
+	if errB != nil {
+		rep.failure("Failed to read resources from %v: %v", fileB, errB)
+		return
+	}
+	diff := &resourceDiff{rep: rep, deep: deep}
+	idsA := chunkIDSet(providerA)
+	idsB := chunkIDSet(providerB)
+	allIDs := make([]int, 0, len(idsA)+len(idsB))
+	for id := range idsA {
+		allIDs = append(allIDs, int(id))
+	}
+	for id := range idsB {
+		if !idsA[id] {
+			allIDs = append(allIDs, int(id))
+		}
+	}
+	sort.Ints(allIDs)
+
+	for _, value := range allIDs {
+		chunkID := chunk.ID(uint16(value))
+		diff.chunks(chunkID, providerA, idsA[uint16(value)], providerB, idsB[uint16(value)])
+	}
+}
+
+func chunkIDSet(provider chunk.Provider) map[uint16]bool {
+	ids := make(map[uint16]bool)
+	for _, id := range provider.IDs() {
+		ids[id.Value()] = true
+	}
+	return ids
+}
+
+func (diff *resourceDiff) chunks(chunkID chunk.Identifier, providerA chunk.Provider, inA bool, providerB chunk.Provider, inB bool) {
+	var chunkA, chunkB *chunk.Chunk
+	var err error
+
+	if inA {
+		if chunkA, err = providerA.Chunk(chunkID); err != nil {
+			diff.rep.failure("Failed to read chunk %04X: %v", chunkID.Value(), err)
+			return
+		}
+	}
+	if inB {
+		if chunkB, err = providerB.Chunk(chunkID); err != nil {
+			diff.rep.failure("Failed to read chunk %04X: %v", chunkID.Value(), err)
+			return
+		}
+	}
+	if chunkA == nil {
+		diff.report(chunkID, -1, diffAdded, "%s, %d blocks", contentTypeName(chunkB.ContentType), chunkB.BlockCount())
+		return
+	}
+	if chunkB == nil {
+		diff.report(chunkID, -1, diffRemoved, "%s, %d blocks", contentTypeName(chunkA.ContentType), chunkA.BlockCount())
+		return
+	}
+	if chunkA.ContentType != chunkB.ContentType {
+		diff.report(chunkID, -1, diffModified, "content type %s -> %s",
+			contentTypeName(chunkA.ContentType), contentTypeName(chunkB.ContentType))
+	}
+	if chunkA.Compressed != chunkB.Compressed {
+		diff.report(chunkID, -1, diffModified, "compressed %v -> %v", chunkA.Compressed, chunkB.Compressed)
+	}
+	if chunkA.Fragmented != chunkB.Fragmented {
+		diff.report(chunkID, -1, diffModified, "fragmented %v -> %v", chunkA.Fragmented, chunkB.Fragmented)
+	}
+	if chunkA.BlockCount() != chunkB.BlockCount() {
+		diff.report(chunkID, -1, diffModified, "block count %d -> %d", chunkA.BlockCount(), chunkB.BlockCount())
+	}
+	diff.blocks(chunkID, chunkA, chunkB)
+}
+
+func (diff *resourceDiff) blocks(chunkID chunk.Identifier, chunkA, chunkB *chunk.Chunk) {
+	blocksA, errA := readBlocks(chunkA)
+	blocksB, errB := readBlocks(chunkB)
+	if (errA != nil) || (errB != nil) {
+		diff.rep.failure("Failed to read blocks of chunk %04X", chunkID.Value())
+		return
+	}
+	for blockID := 0; (blockID < len(blocksA)) || (blockID < len(blocksB)); blockID++ {
+		if blockID >= len(blocksA) {
+			diff.report(chunkID, blockID, diffAdded, "%d bytes", len(blocksB[blockID]))
+		} else if blockID >= len(blocksB) {
+			diff.report(chunkID, blockID, diffRemoved, "%d bytes", len(blocksA[blockID]))
+		} else if !bytes.Equal(blocksA[blockID], blocksB[blockID]) {
+			description := ""
+			if diff.deep && (chunkA.ContentType == chunkB.ContentType) {
+				description = describeBlockChange(chunkA.ContentType, blocksA[blockID], blocksB[blockID])
+			}
+			if len(description) == 0 {
+				description = describeByteChange(blocksA[blockID], blocksB[blockID])
+			}
+			diff.report(chunkID, blockID, diffModified, "%s", description)
+		}
+	}
+}
+
+func describeByteChange(dataA, dataB []byte) string {
+	if len(dataA) != len(dataB) {
+		return fmt.Sprintf("size %d -> %d bytes", len(dataA), len(dataB))
+	}
+	differing := 0
+	for index := range dataA {
+		if dataA[index] != dataB[index] {
+			differing++
+		}
+	}
+	return fmt.Sprintf("%d of %d bytes differ", differing, len(dataA))
+}
+
+// describeBlockChange decodes the given blocks and describes the semantic change.
+// Returns an empty string if the content type is not supported or the blocks can not be decoded.
+func describeBlockChange(contentType chunk.ContentType, dataA, dataB []byte) string {
+	switch contentType {
+	case chunk.Bitmap:
+		return describeBitmapChange(dataA, dataB)
+	case chunk.Palette:
+		return describePaletteChange(dataA, dataB)
+	case chunk.Text:
+		return describeTextChange(dataA, dataB)
+	}
+	return ""
+}
+
+func describeBitmapChange(dataA, dataB []byte) string {
+	imgA, errA := convert.DecodeBitmap(dataA)
+	imgB, errB := convert.DecodeBitmap(dataB)
+	if (errA != nil) || (errB != nil) {
+		return ""
+	}
+	sizeA := imgA.Bounds().Size()
+	sizeB := imgB.Bounds().Size()
+	if sizeA != sizeB {
+		return fmt.Sprintf("bitmap %dx%d -> %dx%d", sizeA.X, sizeA.Y, sizeB.X, sizeB.Y)
+	}
+	differing := 0
+	for index := range imgA.Pix {
+		if imgA.Pix[index] != imgB.Pix[index] {
+			differing++
+		}
+	}
+	description := fmt.Sprintf("bitmap %dx%d, %d pixels changed", sizeA.X, sizeA.Y, differing)
+	if len(imgA.Palette) != len(imgB.Palette) {
+		description += ", private palette changed"
+	} else if changed := countColorChanges(imgA.Palette, imgB.Palette); changed > 0 {
+		description += fmt.Sprintf(", %d private palette colors changed", changed)
+	}
+	return description
+}
+
+func describePaletteChange(dataA, dataB []byte) string {
+	palA, errA := image.LoadPalette(bytes.NewReader(dataA))
+	palB, errB := image.LoadPalette(bytes.NewReader(dataB))
+	if (errA != nil) || (errB != nil) || (len(palA) != len(palB)) {
+		return ""
+	}
+	return fmt.Sprintf("palette, %d colors changed", countColorChanges(palA, palB))
+}
+
+func describeTextChange(dataA, dataB []byte) string {
+	cp := text.DefaultCodepage()
+	return fmt.Sprintf("text changed: %q -> %q", cp.Decode(dataA), cp.Decode(dataB))
+}
+
+func countColorChanges(palA, palB color.Palette) int {
+	changed := 0
+	for index := range palA {
+		rA, gA, bA, aA := palA[index].RGBA()
+		rB, gB, bB, aB := palB[index].RGBA()
+		if (rA != rB) || (gA != gB) || (bA != bB) || (aA != aB) {
+			changed++
+		}
+	}
+	return changed
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/inkyblackness/res/chunk"
+)
+
+const manifestFileName = "manifest.json"
+
+// exportManifest describes the files produced by exports into a folder,
+// together with the meta information of their source chunks.
+type exportManifest struct {
+	Chunks []*manifestChunk `json:"chunks"`
+}
+
+// manifestChunk describes an exported chunk and the options used for the export.
+type manifestChunk struct {
+	ChunkID         string           `json:"chunk"`
+	ContentType     string           `json:"contentType"`
+	Compressed      bool             `json:"compressed"`
+	Fragmented      bool             `json:"fragmented"`
+	Raw             bool             `json:"raw,omitempty"`
+	Palette         string           `json:"palette,omitempty"`
+	PaletteID       string           `json:"paletteId,omitempty"`
+	FramesPerSecond float64          `json:"fps"`
+	Blocks          []*manifestBlock `json:"blocks"`
+}
+
+// manifestBlock describes the files produced for one block.
+// File names are relative to the folder of the manifest.
+type manifestBlock struct {
+	BlockID   int      `json:"block"`
+	Size      int      `json:"size"`
+	Converter string   `json:"converter"`
+	Files     []string `json:"files"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// readExportManifest reads the manifest of given folder.
+// If the folder has no manifest, the returned error satisfies os.IsNotExist().
+func readExportManifest(folder string) (manifest *exportManifest, err error) {
+	data, err := ioutil.ReadFile(path.Join(folder, manifestFileName))
+	if err != nil {
+		return
+	}
+	manifest = &exportManifest{}
+	err = json.Unmarshal(data, manifest)
+	return
+}
+
+// writeExportManifest saves the manifest into given folder, sorted by chunk and block identifier.
+func writeExportManifest(folder string, manifest *exportManifest) error {
+	sort.Slice(manifest.Chunks, func(a, b int) bool { return manifest.Chunks[a].ChunkID < manifest.Chunks[b].ChunkID })
+	for _, entry := range manifest.Chunks {
+		blocks := entry.Blocks
+		sort.Slice(blocks, func(a, b int) bool { return blocks[a].BlockID < blocks[b].BlockID })
+	}
+	data, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(folder, manifestFileName), data, os.FileMode(0644))
+}
+
+// chunk returns the entry for the given chunk, updated with the meta information of the source.
+// Blocks of a previously existing entry are kept.
+func (manifest *exportManifest) chunk(chunkID chunk.Identifier, source *chunk.Chunk) (entry *manifestChunk) {
+	key := formatChunkID(chunkID)
+
+	for _, existing := range manifest.Chunks {
+		if existing.ChunkID == key {
+			entry = existing
+		}
+	}
+	if entry == nil {
+		entry = &manifestChunk{ChunkID: key}
+		manifest.Chunks = append(manifest.Chunks, entry)
+	}
+	entry.ContentType = contentTypeName(source.ContentType)
+	entry.Compressed = source.Compressed
+	entry.Fragmented = source.Fragmented
+	return
+}
+
+// addBlock stores the outcome of an exported block.
+func (entry *manifestChunk) addBlock(record blockRecord, size int) {
+	block := &manifestBlock{
+		BlockID:   *record.BlockID,
+		Size:      size,
+		Converter: record.Converter,
+		Files:     make([]string, 0, len(record.Files)),
+		Error:     record.Error}
+
+	for _, file := range record.Files {
+		block.Files = append(block.Files, path.Base(file))
+	}
+	for index, existing := range entry.Blocks {
+		if existing.BlockID == block.BlockID {
+			entry.Blocks[index] = block
+			return
+		}
+	}
+	entry.Blocks = append(entry.Blocks, block)
+}
+
+// importableFile returns the name of the file that can be imported to restore the block.
+// Returns an empty string if the block was exported in a format that can not be imported.
+func (block *manifestBlock) importableFile() string {
+	if len(block.Files) == 0 {
+		return ""
+	}
+	switch block.Converter {
+	case "png":
+		// The image is accompanied by its metadata, which is picked up on import.
+		return block.Files[0]
+	case "palette":
+		// The palette is written in several formats, the first one is used.
+		return block.Files[0]
+	case "xml", "po", "csv":
+		// Only the first block refers to the file, which restores all blocks of the chunk.
+		return block.Files[0]
+	}
+	if len(block.Files) != 1 {
+		return ""
+	}
+	switch block.Converter {
+	case "raw", "wav":
+		return block.Files[0]
+	case "media":
+		// Only media containers that were exported as pure audio can be restored.
+		if path.Ext(block.Files[0]) == ".wav" {
+			return block.Files[0]
+		}
+	}
+	return ""
+}
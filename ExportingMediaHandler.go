@@ -9,6 +9,7 @@ import (
 
 	"github.com/inkyblackness/res/audio/mem"
 	"github.com/inkyblackness/res/movi"
+	"github.com/inkyblackness/res/text"
 
 	"github.com/inkyblackness/chunkie/convert/wav"
 )
@@ -40,26 +41,44 @@ type exportingMediaHandler struct {
 	lastFrame          *image.Paletted
 
 	framesPerSecond float32
+
+	// codepage, if not nil, is used to decode subtitles instead of the default code page.
+	codepage text.Codepage
+
+	files []string
+	err   error
 }
 
-func newExportingMediaHandler(fileBaseName string, mediaDuration float32, framesPerSecond float32, sampleRate float32) *exportingMediaHandler {
+func newExportingMediaHandler(fileBaseName string, mediaDuration float32, framesPerSecond float32, sampleRate float32,
+	codepage text.Codepage) *exportingMediaHandler {
 	return &exportingMediaHandler{
 		mediaDuration:   mediaDuration,
 		fileBaseName:    fileBaseName,
 		subtitles:       make(map[movi.SubtitleControl]*subtitleEntry),
 		framesPerSecond: framesPerSecond,
-		sampleRate:      sampleRate}
+		sampleRate:      sampleRate,
+		codepage:        codepage}
 }
 
-func (handler *exportingMediaHandler) finish() {
+// finish writes all pending data and returns the first error that occurred while exporting.
+func (handler *exportingMediaHandler) finish() error {
 	handler.writeLastFramesUntil(handler.mediaDuration)
 	for _, entry := range handler.subtitles {
 		handler.finishSubtitle(entry, handler.mediaDuration)
-		_ = entry.file.Close()
+		handler.fail(entry.file.Close())
 	}
 	if len(handler.audio) > 0 {
 		soundData := mem.NewL8SoundData(handler.sampleRate, handler.audio)
-		wav.ExportToWav(handler.fileBaseName+".wav", soundData)
+		handler.fail(wav.ExportToWav(handler.fileBaseName+".wav", soundData))
+		handler.files = append(handler.files, handler.fileBaseName+".wav")
+	}
+	return handler.err
+}
+
+// fail remembers the given error, should it be the first one.
+func (handler *exportingMediaHandler) fail(err error) {
+	if handler.err == nil {
+		handler.err = err
 	}
 }
 
@@ -67,22 +86,43 @@ func (handler *exportingMediaHandler) OnAudio(timestamp float32, samples []byte)
 	handler.audio = append(handler.audio, samples...)
 }
 
-func (handler *exportingMediaHandler) OnSubtitle(timestamp float32, control movi.SubtitleControl, text string) {
+func (handler *exportingMediaHandler) OnSubtitle(timestamp float32, control movi.SubtitleControl, subtitle string) {
 	if control != movi.SubtitleArea {
 		entry := handler.subtitles[control]
 
 		if entry == nil {
-			file, _ := os.Create(handler.fileBaseName + "_" + subtitleLanguages[control] + ".srt")
+			name := handler.fileBaseName + "_" + subtitleLanguages[control] + ".srt"
+			file, err := os.Create(name)
+			if err != nil {
+				handler.fail(err)
+				return
+			}
 			entry = &subtitleEntry{file: file}
+			handler.files = append(handler.files, name)
 			handler.subtitles[control] = entry
 		}
 		handler.finishSubtitle(entry, timestamp)
 		entry.timestamp = timestamp
-		entry.text = text
+		entry.text = handler.decodeSubtitle(subtitle)
 		entry.counter++
 	}
 }
 
+// decodeSubtitle decodes the given subtitle, which the dispatcher decoded with the default code page,
+// with the code page of the handler.
+func (handler *exportingMediaHandler) decodeSubtitle(subtitle string) string {
+	return decodeSubtitle(handler.codepage, subtitle)
+}
+
+// decodeSubtitle decodes a subtitle, as provided by a media dispatcher, with the given code page.
+// The subtitle is returned unchanged if the code page is nil.
+func decodeSubtitle(cp text.Codepage, subtitle string) string {
+	if cp == nil {
+		return subtitle
+	}
+	return cp.Decode(text.DefaultCodepage().Encode(subtitle))
+}
+
 func (handler *exportingMediaHandler) OnVideo(timestamp float32, frame *image.Paletted) {
 	handler.writeLastFramesUntil(timestamp)
 
@@ -130,10 +170,15 @@ func (handler *exportingMediaHandler) writeLastFramesUntil(timestamp float32) {
 }
 
 func (handler *exportingMediaHandler) writeFrame(frame *image.Paletted, name string) {
-	file, _ := os.Create(name)
+	file, err := os.Create(name)
+	if err != nil {
+		handler.fail(err)
+		return
+	}
 
-	png.Encode(file, frame)
-	file.Close()
+	handler.fail(png.Encode(file, frame))
+	handler.fail(file.Close())
+	handler.files = append(handler.files, name)
 }
 
 func (handler *exportingMediaHandler) timedFileName(timestamp float32) string {
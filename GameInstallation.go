@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/inkyblackness/res/chunk"
+)
+
+// gameDataDirs are the directories, relative to the game directory, in which resource files are searched.
+var gameDataDirs = []string{".", "data", filepath.Join("res", "data")}
+
+// gameTextureFileName is the resource file containing the textures referenced by models.
+const gameTextureFileName = "citmat.res"
+
+// gameLanguageFileNames maps the string resource files to the language they provide.
+var gameLanguageFileNames = map[string]string{
+	"cybstrng.res": "english",
+	"frnstrng.res": "french",
+	"gerstrng.res": "german"}
+
+// gameCDFileNames are resource files only present in the CD version, which has speech.
+var gameCDFileNames = []string{"citalog.res", "citbark.res"}
+
+// gameConfig is the content of the configuration file.
+type gameConfig struct {
+	GameDir string `json:"gameDir"`
+}
+
+// gameConfigFileName returns the path of the configuration file. Returns an empty string if there is no
+// configuration directory.
+func gameConfigFileName() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "chunkie", "config.json")
+}
+
+// gameInstallation describes the resource files of an installed game.
+type gameInstallation struct {
+	Dir string `json:"dir"`
+	// Variant is either "cd" or "floppy".
+	Variant   string   `json:"variant"`
+	Languages []string `json:"languages"`
+	// Files maps the lower-case names of the resource files to their paths.
+	Files map[string]string `json:"files"`
+
+	stores map[string]*chunk.ProviderBackedStore
+}
+
+// gameArguments returns the game installation specified with --game-dir, or in the configuration file.
+// Returns nil if neither specifies one.
+func gameArguments(arguments map[string]interface{}) (*gameInstallation, error) {
+	if game, err := explicitGameArguments(arguments); (game != nil) || (err != nil) {
+		return game, err
+	}
+	return configuredGame()
+}
+
+// explicitGameArguments returns the game installation specified with --game-dir.
+// Returns nil if the option is not given.
+func explicitGameArguments(arguments map[string]interface{}) (*gameInstallation, error) {
+	if dirArgument := arguments["--game-dir"]; dirArgument != nil {
+		return findGameInstallation(dirArgument.(string))
+	}
+	return nil, nil
+}
+
+// configuredGame returns the game installation specified in the configuration file.
+// Returns nil if there is no configuration file, or it does not specify a game directory.
+func configuredGame() (*gameInstallation, error) {
+	configFileName := gameConfigFileName()
+	if len(configFileName) == 0 {
+		return nil, nil
+	}
+	configData, err := ioutil.ReadFile(configFileName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var config gameConfig
+	if err = json.Unmarshal(configData, &config); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %v: %v", configFileName, err)
+	}
+	if len(config.GameDir) == 0 {
+		return nil, nil
+	}
+	return findGameInstallation(config.GameDir)
+}
+
+// findGameInstallation collects the resource files of the game in the given directory and determines
+// the variant of the installation.
+func findGameInstallation(dir string) (*gameInstallation, error) {
+	game := &gameInstallation{
+		Dir:    dir,
+		Files:  make(map[string]string),
+		stores: make(map[string]*chunk.ProviderBackedStore)}
+	for _, dataDir := range gameDataDirs {
+		entries, _ := ioutil.ReadDir(filepath.Join(dir, dataDir))
+		for _, entry := range entries {
+			name := strings.ToLower(entry.Name())
+			if _, existing := game.Files[name]; !entry.IsDir() && (filepath.Ext(name) == ".res") && !existing {
+				game.Files[name] = filepath.Join(dir, dataDir, entry.Name())
+			}
+		}
+	}
+	if len(game.file(gamePaletteFileName)) == 0 {
+		return nil, fmt.Errorf("no game installation found in %v, %v is missing", dir, gamePaletteFileName)
+	}
+	game.Variant = "floppy"
+	for _, name := range gameCDFileNames {
+		if len(game.file(name)) > 0 {
+			game.Variant = "cd"
+		}
+	}
+	for name, language := range gameLanguageFileNames {
+		if len(game.file(name)) > 0 {
+			game.Languages = append(game.Languages, language)
+		}
+	}
+	sort.Strings(game.Languages)
+	return game, nil
+}
+
+// file returns the path of the resource file with given name. Returns an empty string if the game
+// does not have it.
+func (game *gameInstallation) file(name string) string {
+	return game.Files[strings.ToLower(name)]
+}
+
+// store returns the loaded resource file with given name.
+func (game *gameInstallation) store(name string) (*chunk.ProviderBackedStore, error) {
+	fileName := game.file(name)
+	if len(fileName) == 0 {
+		return nil, fmt.Errorf("game has no %v", name)
+	}
+	if store, loaded := game.stores[fileName]; loaded {
+		return store, nil
+	}
+	store, err := loadResourceFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	game.stores[fileName] = store
+	return store, nil
+}
+
+// findChunk searches all resource files of the game for the chunk with given identifier.
+func (game *gameInstallation) findChunk(chunkID chunk.Identifier) (*chunk.Chunk, error) {
+	var names []string
+	for name := range game.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		store, err := game.store(name)
+		if err != nil {
+			continue
+		}
+		if !hasChunk(store, chunkID) {
+			continue
+		}
+		return store.Chunk(chunkID)
+	}
+	return nil, fmt.Errorf("chunk %v not found in game directory %v", chunkID, game.Dir)
+}
+
+// describeGame reports the game installation.
+func describeGame(rep *reporter, game *gameInstallation) {
+	var names []string
+	for name := range game.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rep.entry(game, fmt.Sprintf("%v: %v version, languages: %v\nResource files: %v",
+		game.Dir, game.Variant, strings.Join(game.Languages, ", "), strings.Join(names, ", ")))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"regexp"
+	"strings"
+
+	"github.com/inkyblackness/res/chunk"
+	"github.com/inkyblackness/res/movi"
+	"github.com/inkyblackness/res/text"
+
+	"github.com/inkyblackness/chunkie/convert"
+)
+
+// grepRecord describes a text that matches the search pattern.
+type grepRecord struct {
+	File        string `json:"file"`
+	ChunkID     string `json:"chunk"`
+	BlockID     int    `json:"block"`
+	ContentType string `json:"contentType"`
+	// Language and Timestamp are set for subtitles of media.
+	Language  string   `json:"language,omitempty"`
+	Timestamp *float32 `json:"timestamp,omitempty"`
+	Text      string   `json:"text"`
+}
+
+// subtitleCollector is a media handler that only keeps the subtitles.
+type subtitleCollector struct {
+	codepage  text.Codepage
+	subtitles []grepRecord
+}
+
+func (collector *subtitleCollector) OnAudio(timestamp float32, samples []byte) {}
+
+func (collector *subtitleCollector) OnVideo(timestamp float32, frame *image.Paletted) {}
+
+func (collector *subtitleCollector) OnSubtitle(timestamp float32, control movi.SubtitleControl, subtitle string) {
+	if control != movi.SubtitleArea {
+		temp := timestamp
+		collector.subtitles = append(collector.subtitles, grepRecord{
+			Language:  subtitleLanguages[control],
+			Timestamp: &temp,
+			Text:      decodeSubtitle(collector.codepage, subtitle)})
+	}
+}
+
+// grepResources searches the texts and media subtitles of the given resource files for the pattern,
+// a regular expression, and reports each matching text.
+func grepResources(rep *reporter, pattern string, resourceFiles []string, ignoreCase bool, cp text.Codepage) {
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	expression, expressionErr := regexp.Compile(pattern)
+	if expressionErr != nil {
+		rep.failure("Invalid pattern: %v", expressionErr)
+		return
+	}
+	for _, resourceFile := range resourceFiles {
+		provider, loadErr := loadResourceFile(resourceFile)
+		if loadErr != nil {
+			rep.failure("Failed to read resource file %v: %v", resourceFile, loadErr)
+			continue
+		}
+		for _, chunkID := range provider.IDs() {
+			holder, chunkErr := provider.Chunk(chunkID)
+			if chunkErr != nil {
+				rep.failure("Failed to read chunk %v of %v: %v", chunkID, resourceFile, chunkErr)
+				continue
+			}
+			for _, candidate := range searchableTexts(rep, resourceFile, chunkID, holder, cp) {
+				if expression.MatchString(candidate.Text) {
+					reportMatch(rep, candidate)
+				}
+			}
+		}
+	}
+}
+
+// searchableTexts returns the texts of the given chunk: all blocks of text chunks and the subtitles of media.
+func searchableTexts(rep *reporter, resourceFile string, chunkID chunk.Identifier, holder *chunk.Chunk,
+	cp text.Codepage) (candidates []grepRecord) {
+	base := grepRecord{File: resourceFile, ChunkID: formatChunkID(chunkID), ContentType: contentTypeName(holder.ContentType)}
+	switch holder.ContentType {
+	case chunk.Text:
+		texts, err := convert.DecodeTexts(holder, cp)
+		if err != nil {
+			rep.failure("Failed to read texts of chunk %v of %v: %v", chunkID, resourceFile, err)
+		}
+		for blockID, value := range texts {
+			candidate := base
+			candidate.BlockID = blockID
+			candidate.Text = value
+			candidates = append(candidates, candidate)
+		}
+	case chunk.Media:
+		blocks, err := readBlocks(holder)
+		if err != nil {
+			rep.failure("Failed to read media of chunk %v of %v: %v", chunkID, resourceFile, err)
+		}
+		for blockID, blockData := range blocks {
+			collector := &subtitleCollector{codepage: cp}
+			if err := dispatchMedia(blockData, collector); err != nil {
+				rep.failure("Failed to read media of block %d of chunk %v of %v: %v", blockID, chunkID, resourceFile, err)
+			}
+			for _, subtitle := range collector.subtitles {
+				candidate := subtitle
+				candidate.File, candidate.ChunkID, candidate.ContentType = base.File, base.ChunkID, base.ContentType
+				candidate.BlockID = blockID
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+	return
+}
+
+// dispatchMedia passes all content of the media container in given block data to the handler.
+func dispatchMedia(blockData []byte, handler movi.MediaHandler) error {
+	container, err := movi.Read(bytes.NewReader(blockData))
+	if err != nil {
+		return err
+	}
+	dispatcher := movi.NewMediaDispatcher(container, handler)
+	more := true
+	for more && err == nil {
+		more, err = dispatcher.DispatchNext()
+	}
+	return err
+}
+
+func reportMatch(rep *reporter, match grepRecord) {
+	location := fmt.Sprintf("%v block %d", match.ChunkID, match.BlockID)
+	if match.Timestamp != nil {
+		location += fmt.Sprintf(" (%v subtitle at %.3fs)", match.Language, *match.Timestamp)
+	}
+	rep.entry(&match, fmt.Sprintf("%v: %v: %v", match.File, location, strings.Replace(match.Text, "\n", `\n`, -1)))
+}
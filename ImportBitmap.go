@@ -0,0 +1,98 @@
+package main
+
+import (
+	goImage "image"
+	"image/color"
+	"os"
+
+	"github.com/inkyblackness/res/image"
+
+	"github.com/inkyblackness/chunkie/convert"
+)
+
+// bitmapMetadataFileName returns the name of the file holding the metadata of an exported bitmap.
+func bitmapMetadataFileName(imageFileName string) string {
+	return imageFileName + ".json"
+}
+
+// importBitmap converts a PNG file into bitmap block data.
+// If the replaced bitmap (previous) has a private palette, the image is mapped to this palette and the
+// result keeps it. Otherwise, if the options specify a palette, the image is mapped to it by color.
+// Images that are not paletted require a palette.
+// Metadata written on export (compression, flags and anchor) is restored, unless overridden by the options.
+// The header is built from the metadata and the options before the image is encoded.
+func importBitmap(record *blockRecord, sourceFile string, previous []byte, options importOptions) []byte {
+	img, readErr := convert.ReadPng(sourceFile)
+	if readErr != nil {
+		record.fail("Failed to read image from %v: %v", sourceFile, readErr)
+		return nil
+	}
+	meta, metaErr := convert.LoadBitmapMetadata(bitmapMetadataFileName(sourceFile))
+	if (metaErr != nil) && !os.IsNotExist(metaErr) {
+		record.fail("Failed to read bitmap metadata of %v: %v", sourceFile, metaErr)
+		return nil
+	}
+	if options.compressed != nil {
+		meta.Compressed = *options.compressed
+	}
+	if options.transparency != nil {
+		if *options.transparency {
+			meta.Flags |= uint16(image.Transparent)
+		} else {
+			meta.Flags &^= uint16(image.Transparent)
+		}
+	}
+	if options.anchor != nil {
+		meta.Anchor = *options.anchor
+	}
+	var targetPalette color.Palette
+	withPrivatePalette := options.privatePalette
+	if !withPrivatePalette && (previous != nil) {
+		previousImg, previousErr := convert.DecodeBitmap(previous)
+		if previousErr != nil {
+			record.warn("Failed to decode the replaced bitmap, its palette is not considered: %v", previousErr)
+		} else if len(previousImg.Palette) > 0 {
+			targetPalette = previousImg.Palette
+			withPrivatePalette = true
+		}
+	}
+	if targetPalette == nil {
+		var paletteErr error
+		targetPalette, paletteErr = options.palette()
+		if paletteErr != nil {
+			record.fail("Failed to load palette: %v", paletteErr)
+			return nil
+		}
+	}
+	keepImagePalette := options.privatePalette || (!withPrivatePalette && meta.PrivatePalette)
+	withPrivatePalette = withPrivatePalette || meta.PrivatePalette
+	palettedImg, isPaletted := img.(*goImage.Paletted)
+	if !isPaletted {
+		if targetPalette == nil {
+			record.fail("%v is not a paletted image, a palette (--pal) is required to convert it", sourceFile)
+			return nil
+		}
+		var changedPixels int
+		palettedImg, changedPixels = convert.Quantize(img, targetPalette, options.dither)
+		if changedPixels > 0 {
+			record.warn("%d pixels of %v changed color when mapped to the palette", changedPixels, sourceFile)
+		}
+	} else if (targetPalette != nil) && !keepImagePalette {
+		var unmatchedColors int
+		palettedImg, unmatchedColors = convert.RemapPalette(palettedImg, targetPalette)
+		if unmatchedColors > 0 {
+			if options.strictPalette {
+				record.fail("%d colors of %v have no exact match in the palette", unmatchedColors, sourceFile)
+				return nil
+			}
+			record.warn("%d colors of %v have no exact match in the palette, using nearest colors", unmatchedColors, sourceFile)
+		}
+	}
+
+	data, encodeErr := convert.EncodeImage(palettedImg, meta.Header(), withPrivatePalette)
+	if encodeErr != nil {
+		record.fail("Failed to encode bitmap from %v: %v", sourceFile, encodeErr)
+		return nil
+	}
+	return data
+}
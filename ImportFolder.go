@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/inkyblackness/res/chunk"
+)
+
+var exportedFileName = regexp.MustCompile(`^([0-9A-Fa-f]{4})_([0-9]{3,})\.([a-z]+)$`)
+
+// folderImportExtensions lists the file types that are picked up when importing a folder.
+// Files exported only for viewing (such as .obj or .srt) are not imported.
+var folderImportExtensions = map[string]bool{
+	".bin": true,
+	".csv": true,
+	".pal": true,
+	".png": true,
+	".po":  true,
+	".wav": true,
+	".xml": true}
+
+// parseExportedFileName extracts the chunk and block identifier from a file name
+// that follows the export naming scheme XXXX_YYY.ZZZ.
+func parseExportedFileName(fileName string) (chunkID chunk.Identifier, blockID int, ok bool) {
+	match := exportedFileName.FindStringSubmatch(fileName)
+	if match == nil {
+		return
+	}
+	chunkValue, _ := strconv.ParseUint(match[1], 16, 16)
+	blockValue, blockErr := strconv.ParseUint(match[2], 10, 16)
+	if blockErr != nil {
+		return
+	}
+	return chunk.ID(uint16(chunkValue)), int(blockValue), true
+}
+
+// importFolder imports all exported files of given folder into the resource file.
+// If the folder contains an export manifest, it describes which files to import and
+// the meta information of the chunks is restored. Otherwise, all files following the
+// export naming scheme are imported.
+// The resource file is written only once, after all files were processed.
+func importFolder(rep *reporter, resourceFile string, folder string, options importOptions, save saveOptions) {
+	store, loadErr := loadResourceFile(resourceFile)
+	if loadErr != nil {
+		rep.failure("Failed to read resources from input file: %v", loadErr)
+		return
+	}
+	var imported bool
+	manifest, manifestErr := readExportManifest(folder)
+	if manifestErr == nil {
+		imported = importManifest(rep, store, folder, manifest, options)
+	} else if os.IsNotExist(manifestErr) {
+		imported = importNamedFiles(rep, store, folder, options)
+	} else {
+		rep.failure("Failed to read manifest: %v", manifestErr)
+		return
+	}
+	if !imported {
+		rep.failure("Not all files could be imported, leaving %v unchanged", resourceFile)
+		return
+	}
+	err := saveResourceFile(resourceFile, store, save)
+	if err != nil {
+		rep.failure("Failed to save file: %v", err)
+	}
+}
+
+func importManifest(rep *reporter, store *chunk.ProviderBackedStore, folder string, manifest *exportManifest,
+	options importOptions) (imported bool) {
+	imported = true
+	for _, entry := range manifest.Chunks {
+		chunkValue, idErr := strconv.ParseUint(entry.ChunkID, 0, 16)
+		if idErr != nil {
+			rep.failure("Invalid chunk identifier in manifest: %v", entry.ChunkID)
+			imported = false
+			continue
+		}
+		chunkID := chunk.ID(uint16(chunkValue))
+		contentType, typeErr := parseContentType(entry.ContentType)
+		if typeErr != nil {
+			rep.failure("Invalid content type in manifest for chunk %v: %v", entry.ChunkID, typeErr)
+			imported = false
+			continue
+		}
+		modChunk, chunkErr := store.Chunk(chunkID)
+		if chunkErr != nil {
+			rep.failure("Failed to access chunk to modify: %v", chunkErr)
+			imported = false
+			continue
+		}
+		if flagChanges := chunkFlagChanges(modChunk, contentType, entry); len(flagChanges) > 0 {
+			rep.chunkChange(chunkID, contentType, "%v", strings.Join(flagChanges, ", "))
+		}
+		modChunk.ContentType = contentType
+		modChunk.Compressed = entry.Compressed
+		modChunk.Fragmented = entry.Fragmented
+
+		for _, block := range entry.Blocks {
+			sourceFile := block.importableFile()
+			if len(sourceFile) == 0 {
+				continue
+			}
+			record := newBlockRecord(chunkID, block.BlockID)
+			imported = importBlock(&record, store, chunkID, block.BlockID, path.Join(folder, sourceFile), options) && imported
+			rep.block(record)
+		}
+	}
+	reportUnmanagedFiles(rep, folder, manifest)
+	return
+}
+
+// reportUnmanagedFiles warns about files that follow the export naming scheme, yet are not listed in the
+// manifest, such as the textures exported along with models. They are not imported.
+func reportUnmanagedFiles(rep *reporter, folder string, manifest *exportManifest) {
+	entries, dirErr := ioutil.ReadDir(folder)
+	if dirErr != nil {
+		rep.failure("Failed to read folder: %v", dirErr)
+		return
+	}
+	listed := make(map[string]bool)
+	for _, entry := range manifest.Chunks {
+		for _, block := range entry.Blocks {
+			for _, fileName := range block.Files {
+				listed[fileName] = true
+			}
+		}
+	}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || listed[fileName] || !folderImportExtensions[strings.ToLower(path.Ext(fileName))] {
+			continue
+		}
+		if _, _, ok := parseExportedFileName(fileName); ok {
+			rep.warning("Ignoring %v, it is not listed in the manifest", fileName)
+		}
+	}
+}
+
+// chunkFlagChanges describes how the meta information in the manifest differs from the chunk.
+func chunkFlagChanges(modChunk *chunk.Chunk, contentType chunk.ContentType, entry *manifestChunk) (changes []string) {
+	if modChunk.ContentType != contentType {
+		changes = append(changes, fmt.Sprintf("content type %v -> %v", contentTypeName(modChunk.ContentType), contentTypeName(contentType)))
+	}
+	if modChunk.Compressed != entry.Compressed {
+		changes = append(changes, fmt.Sprintf("compressed %v -> %v", modChunk.Compressed, entry.Compressed))
+	}
+	if modChunk.Fragmented != entry.Fragmented {
+		changes = append(changes, fmt.Sprintf("fragmented %v -> %v", modChunk.Fragmented, entry.Fragmented))
+	}
+	return
+}
+
+func importNamedFiles(rep *reporter, store *chunk.ProviderBackedStore, folder string, options importOptions) (imported bool) {
+	entries, dirErr := ioutil.ReadDir(folder)
+	if dirErr != nil {
+		rep.failure("Failed to read folder: %v", dirErr)
+		return false
+	}
+	imported = true
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !folderImportExtensions[strings.ToLower(path.Ext(fileName))] {
+			continue
+		}
+		chunkID, blockID, ok := parseExportedFileName(fileName)
+		if !ok {
+			continue
+		}
+		record := newBlockRecord(chunkID, blockID)
+		imported = importBlock(&record, store, chunkID, blockID, path.Join(folder, fileName), options) && imported
+		rep.block(record)
+	}
+	return
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/inkyblackness/res/chunk"
+)
+
+// listChunks reports the directory of all chunks of the given provider.
+// If filter is not nil, only chunks of the contained content types are listed.
+func listChunks(rep *reporter, provider chunk.Provider, filter map[chunk.ContentType]bool) {
+	for _, chunkID := range provider.IDs() {
+		listedChunk, chunkErr := provider.Chunk(chunkID)
+
+		if chunkErr != nil {
+			rep.failure("Failed to read chunk %04X: %v", chunkID.Value(), chunkErr)
+			continue
+		}
+		if (filter != nil) && !filter[listedChunk.ContentType] {
+			continue
+		}
+		record := chunkRecord{
+			ChunkID:     formatChunkID(chunkID),
+			ContentType: contentTypeName(listedChunk.ContentType),
+			Compressed:  listedChunk.Compressed,
+			Fragmented:  listedChunk.Fragmented,
+			BlockSizes:  make([]int, 0, listedChunk.BlockCount())}
+		blockSizes := make([]string, 0, listedChunk.BlockCount())
+		for blockID := 0; blockID < listedChunk.BlockCount(); blockID++ {
+			size := blockSize(listedChunk, blockID)
+			record.BlockSizes = append(record.BlockSizes, size)
+			if size < 0 {
+				blockSizes = append(blockSizes, "?")
+			} else {
+				blockSizes = append(blockSizes, fmt.Sprintf("%d", size))
+			}
+		}
+		rep.entry(&record, fmt.Sprintf("%s  %-9s  %-10s  %-10s  %3d  %s", record.ChunkID, record.ContentType,
+			flagText(record.Compressed, "compressed"), flagText(record.Fragmented, "fragmented"),
+			len(blockSizes), strings.Join(blockSizes, " ")))
+	}
+}
+
+// blockSize returns the size of the given block in bytes, or -1 if it can not be read.
+func blockSize(holder chunk.BlockProvider, blockID int) int {
+	blockReader, blockErr := holder.Block(blockID)
+	if blockErr != nil {
+		return -1
+	}
+	blockData, dataErr := ioutil.ReadAll(blockReader)
+	if dataErr != nil {
+		return -1
+	}
+	return len(blockData)
+}
+
+func flagText(set bool, name string) string {
+	if set {
+		return name
+	}
+	return "-"
+}
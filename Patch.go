@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/inkyblackness/res/chunk"
+)
+
+// patchMagic identifies patch files and their format version.
+const patchMagic = "CHUNKIEPATCH0001"
+
+// resourcePatch describes the changes between an original and a modified resource file.
+// It only contains the data of changed and added blocks, so it can be distributed
+// without the content of the original file.
+type resourcePatch struct {
+	// BaseChecksum is the SHA-256 sum of the original resource file the patch applies to.
+	BaseChecksum [sha256.Size]byte
+	Deleted      []uint16
+	Chunks       []patchChunk
+}
+
+// patchChunk describes an added or modified chunk.
+type patchChunk struct {
+	ID          uint16
+	ContentType chunk.ContentType
+	Compressed  bool
+	Fragmented  bool
+	BlockCount  int
+	// Blocks contains the data of all added and changed blocks, keyed by block identifier.
+	Blocks map[int][]byte
+}
+
+// createPatch writes a patch file that transforms the original resource file into the modified one.
+func createPatch(rep *reporter, originalFile string, modifiedFile string, patchFile string) {
+	originalData, readErr := ioutil.ReadFile(originalFile)
+	if readErr != nil {
+		rep.failure("Failed to read original file: %v", readErr)
+		return
+	}
+	original, originalErr := loadResourceData(originalData)
+	if originalErr != nil {
+		rep.failure("Failed to read resources from original file: %v", originalErr)
+		return
+	}
+	modified, modifiedErr := loadResourceFile(modifiedFile)
+	if modifiedErr != nil {
+		rep.failure("Failed to read resources from modified file: %v", modifiedErr)
+		return
+	}
+	patch := resourcePatch{BaseChecksum: sha256.Sum256(originalData)}
+	originalIDs := chunkIDSet(original)
+	modifiedIDs := chunkIDSet(modified)
+	for id := range originalIDs {
+		if !modifiedIDs[id] {
+			patch.Deleted = append(patch.Deleted, id)
+		}
+	}
+	sort.Slice(patch.Deleted, func(a, b int) bool { return patch.Deleted[a] < patch.Deleted[b] })
+	for _, chunkID := range modified.IDs() {
+		entry, entryErr := patchChunkFor(original, originalIDs[chunkID.Value()], modified, chunkID)
+		if entryErr != nil {
+			rep.failure("Failed to compare chunk %04X: %v", chunkID.Value(), entryErr)
+			return
+		}
+		if entry != nil {
+			patch.Chunks = append(patch.Chunks, *entry)
+		}
+	}
+
+	if err := writePatch(patchFile, &patch); err != nil {
+		rep.failure("Failed to write patch file: %v", err)
+	}
+}
+
+// patchChunkFor returns the patch entry for given chunk, or nil if the chunk is unchanged.
+func patchChunkFor(original chunk.Provider, inOriginal bool, modified chunk.Provider, chunkID chunk.Identifier) (*patchChunk, error) {
+	modifiedChunk, modifiedErr := modified.Chunk(chunkID)
+	if modifiedErr != nil {
+		return nil, modifiedErr
+	}
+	modifiedBlocks, modifiedBlocksErr := readBlocks(modifiedChunk)
+	if modifiedBlocksErr != nil {
+		return nil, modifiedBlocksErr
+	}
+	var originalChunk *chunk.Chunk
+	var originalBlocks [][]byte
+	if inOriginal {
+		var originalErr error
+		if originalChunk, originalErr = original.Chunk(chunkID); originalErr != nil {
+			return nil, originalErr
+		}
+		if originalBlocks, originalErr = readBlocks(originalChunk); originalErr != nil {
+			return nil, originalErr
+		}
+	}
+	entry := &patchChunk{
+		ID:          chunkID.Value(),
+		ContentType: modifiedChunk.ContentType,
+		Compressed:  modifiedChunk.Compressed,
+		Fragmented:  modifiedChunk.Fragmented,
+		BlockCount:  len(modifiedBlocks),
+		Blocks:      make(map[int][]byte)}
+	for blockID, data := range modifiedBlocks {
+		if (blockID >= len(originalBlocks)) || !bytes.Equal(originalBlocks[blockID], data) {
+			entry.Blocks[blockID] = data
+		}
+	}
+	unchanged := (originalChunk != nil) && (len(entry.Blocks) == 0) &&
+		(len(originalBlocks) == len(modifiedBlocks)) &&
+		(originalChunk.ContentType == modifiedChunk.ContentType) &&
+		(originalChunk.Compressed == modifiedChunk.Compressed) &&
+		(originalChunk.Fragmented == modifiedChunk.Fragmented)
+	if unchanged {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// applyPatch applies the patch file to the original resource file and saves the result as output file.
+// The patch is refused if the original file is not the one the patch was created for.
+func applyPatch(rep *reporter, originalFile string, patchFile string, outputFile string) {
+	patch, patchErr := readPatch(patchFile)
+	if patchErr != nil {
+		rep.failure("Failed to read patch file: %v", patchErr)
+		return
+	}
+	originalData, readErr := ioutil.ReadFile(originalFile)
+	if readErr != nil {
+		rep.failure("Failed to read original file: %v", readErr)
+		return
+	}
+	if sha256.Sum256(originalData) != patch.BaseChecksum {
+		rep.failure("Original file %v does not match the patch, it was created for a different file", originalFile)
+		return
+	}
+	store, storeErr := loadResourceData(originalData)
+	if storeErr != nil {
+		rep.failure("Failed to read resources from original file: %v", storeErr)
+		return
+	}
+	for _, id := range patch.Deleted {
+		store.Del(chunk.ID(id))
+	}
+	for _, entry := range patch.Chunks {
+		if err := applyPatchChunk(store, entry); err != nil {
+			rep.failure("Failed to patch chunk %04X: %v", entry.ID, err)
+			return
+		}
+	}
+
+	if err := saveResourceFile(outputFile, store, saveOptions{}); err != nil {
+		rep.failure("Failed to save file: %v", err)
+	}
+}
+
+func applyPatchChunk(store *chunk.ProviderBackedStore, entry patchChunk) error {
+	chunkID := chunk.ID(entry.ID)
+	blocks := make([][]byte, entry.BlockCount)
+
+	if hasChunk(store, chunkID) {
+		originalChunk, chunkErr := store.Chunk(chunkID)
+		if chunkErr != nil {
+			return chunkErr
+		}
+		originalBlocks, blocksErr := readBlocks(originalChunk)
+		if blocksErr != nil {
+			return blocksErr
+		}
+		copy(blocks, originalBlocks)
+	}
+	for blockID := range blocks {
+		if data, patched := entry.Blocks[blockID]; patched {
+			blocks[blockID] = data
+		} else if blocks[blockID] == nil {
+			return fmt.Errorf("patch is missing data for block %d", blockID)
+		}
+	}
+	store.Put(chunkID, &chunk.Chunk{
+		ContentType:   entry.ContentType,
+		Compressed:    entry.Compressed,
+		Fragmented:    entry.Fragmented,
+		BlockProvider: chunk.MemoryBlockProvider(blocks)})
+	return nil
+}
+
+func writePatch(fileName string, patch *resourcePatch) (err error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	if _, err = io.WriteString(file, patchMagic); err != nil {
+		return
+	}
+	compressor := gzip.NewWriter(file)
+	if err = gob.NewEncoder(compressor).Encode(patch); err != nil {
+		return
+	}
+	return compressor.Close()
+}
+
+func readPatch(fileName string) (patch *resourcePatch, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	magic := make([]byte, len(patchMagic))
+	if _, err = io.ReadFull(file, magic); err != nil {
+		return
+	}
+	if string(magic) != patchMagic {
+		return nil, fmt.Errorf("%v is not a patch file", fileName)
+	}
+	decompressor, err := gzip.NewReader(file)
+	if err != nil {
+		return
+	}
+	patch = &resourcePatch{}
+	if err = gob.NewDecoder(decompressor).Decode(patch); err != nil {
+		return nil, err
+	}
+	// Reading to the end verifies the checksum of the compressed data.
+	if _, err = io.Copy(ioutil.Discard, decompressor); err != nil {
+		return nil, err
+	}
+	return
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/inkyblackness/res/chunk"
+)
+
+func TestPatchRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch resourcePatch
+	}{
+		{"empty patch", resourcePatch{BaseChecksum: sha256.Sum256(nil)}},
+		{"deleted chunks only", resourcePatch{BaseChecksum: sha256.Sum256([]byte("original")), Deleted: []uint16{0x0001, 0x0867}}},
+		{"changed chunks", resourcePatch{
+			BaseChecksum: sha256.Sum256([]byte("original")),
+			Deleted:      []uint16{0x0002},
+			Chunks: []patchChunk{
+				{ID: 0x0867, ContentType: chunk.Text, Fragmented: true, BlockCount: 3,
+					Blocks: map[int][]byte{0: {'a', 0x00}, 2: {'c', 0x00}}},
+				{ID: 0x0100, ContentType: chunk.Bitmap, Compressed: true, BlockCount: 1,
+					Blocks: map[int][]byte{0: bytes.Repeat([]byte{0xAB}, 1000)}}}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "mod.patch")
+			if err := writePatch(fileName, &tc.patch); err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+			read, err := readPatch(fileName)
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if !reflect.DeepEqual(*read, tc.patch) {
+				t.Errorf("patch = %+v, expected %+v", *read, tc.patch)
+			}
+		})
+	}
+}
+
+func TestReadPatchRejectsMalformedFiles(t *testing.T) {
+	compressedGarbage := bytes.NewBuffer(nil)
+	compressor := gzip.NewWriter(compressedGarbage)
+	compressor.Write([]byte("not a gob stream"))
+	compressor.Close()
+
+	validFile := filepath.Join(t.TempDir(), "valid.patch")
+	if err := writePatch(validFile, &resourcePatch{Deleted: []uint16{1, 2, 3}}); err != nil {
+		t.Fatalf("writing valid patch failed: %v", err)
+	}
+	validData, _ := ioutil.ReadFile(validFile)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty file", nil},
+		{"wrong magic", []byte("CHUNKIEPATCH9999rest")},
+		{"magic only", []byte(patchMagic)},
+		{"not compressed", append([]byte(patchMagic), "plain data"...)},
+		{"not a patch structure", append([]byte(patchMagic), compressedGarbage.Bytes()...)},
+		{"truncated", validData[:len(validData)-8]},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "broken.patch")
+			if err := ioutil.WriteFile(fileName, tc.data, 0644); err != nil {
+				t.Fatalf("writing test file failed: %v", err)
+			}
+			if _, err := readPatch(fileName); err == nil {
+				t.Errorf("malformed patch was accepted")
+			}
+		})
+	}
+	if _, err := readPatch(filepath.Join(t.TempDir(), "missing.patch")); err == nil {
+		t.Errorf("missing patch was accepted")
+	}
+}
+
+func TestApplyPatchRefusesDifferentOriginal(t *testing.T) {
+	dir := t.TempDir()
+	originalFile := filepath.Join(dir, "original.res")
+	patchFile := filepath.Join(dir, "mod.patch")
+	outputFile := filepath.Join(dir, "output.res")
+	if err := ioutil.WriteFile(originalFile, []byte("another original"), 0644); err != nil {
+		t.Fatalf("writing test file failed: %v", err)
+	}
+	if err := writePatch(patchFile, &resourcePatch{BaseChecksum: sha256.Sum256([]byte("original"))}); err != nil {
+		t.Fatalf("writing patch failed: %v", err)
+	}
+
+	output := bytes.NewBuffer(nil)
+	rep := newReporter(output, true)
+	applyPatch(rep, originalFile, patchFile, outputFile)
+	if !rep.failed {
+		t.Errorf("patch for a different original was applied")
+	}
+	var record blockRecord
+	if err := json.NewDecoder(output).Decode(&record); err != nil {
+		t.Fatalf("reported output %q is not a record: %v", output.String(), err)
+	}
+	if !strings.Contains(record.Error, "does not match the patch") {
+		t.Errorf("reported error = %q, expected the mismatch of the original", record.Error)
+	}
+	if _, err := os.Stat(outputFile); !os.IsNotExist(err) {
+		t.Errorf("output file was written")
+	}
+}
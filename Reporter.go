@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/inkyblackness/res/chunk"
+)
+
+// blockRecord describes the outcome of processing a single block of a chunk.
+type blockRecord struct {
+	ChunkID     string   `json:"chunk,omitempty"`
+	BlockID     *int     `json:"block,omitempty"`
+	ContentType string   `json:"contentType,omitempty"`
+	Files       []string `json:"files,omitempty"`
+	Converter   string   `json:"converter,omitempty"`
+	Change      string   `json:"change,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+func newBlockRecord(chunkID chunk.Identifier, blockID int) blockRecord {
+	return blockRecord{ChunkID: formatChunkID(chunkID), BlockID: &blockID}
+}
+
+// fail sets the error of the record, if none was set before.
+func (record *blockRecord) fail(format string, a ...interface{}) {
+	if len(record.Error) == 0 {
+		record.Error = fmt.Sprintf(format, a...)
+	}
+}
+
+// warn adds a warning to the record.
+func (record *blockRecord) warn(format string, a ...interface{}) {
+	record.Warnings = append(record.Warnings, fmt.Sprintf(format, a...))
+}
+
+// chunkRecord describes a chunk as a whole.
+type chunkRecord struct {
+	ChunkID     string `json:"chunk"`
+	ContentType string `json:"contentType"`
+	Compressed  bool   `json:"compressed"`
+	Fragmented  bool   `json:"fragmented"`
+	BlockSizes  []int  `json:"blockSizes"`
+}
+
+// diffRecord describes a difference between two resource files.
+type diffRecord struct {
+	ChunkID     string `json:"chunk"`
+	BlockID     *int   `json:"block,omitempty"`
+	Change      string `json:"change"`
+	Description string `json:"description"`
+}
+
+func formatChunkID(chunkID chunk.Identifier) string {
+	return fmt.Sprintf("0x%04X", chunkID.Value())
+}
+
+// reporter writes the outcome of operations to its output, usually the standard output.
+// In text mode, only problems (and changes, if verbose) are reported in a human readable way.
+// In JSON mode, every record is written as one JSON object per line.
+type reporter struct {
+	out        io.Writer
+	jsonOutput bool
+	verbose    bool
+	encoder    *json.Encoder
+
+	// failed is set once any problem was reported.
+	failed bool
+}
+
+func newReporter(out io.Writer, jsonOutput bool) *reporter {
+	return &reporter{out: out, jsonOutput: jsonOutput, encoder: json.NewEncoder(out)}
+}
+
+// block reports the outcome of processing a block.
+func (rep *reporter) block(record blockRecord) {
+	if len(record.Error) > 0 {
+		rep.failed = true
+	}
+	if rep.jsonOutput {
+		rep.encoder.Encode(&record)
+		return
+	}
+	for _, warning := range record.Warnings {
+		fmt.Fprintf(rep.out, "Warning: %s\n", warning)
+	}
+	if len(record.Error) > 0 {
+		fmt.Fprintf(rep.out, "%s\n", record.Error)
+	} else if rep.verbose && (len(record.Change) > 0) && (record.BlockID != nil) {
+		fmt.Fprintf(rep.out, "%s block %d: %s\n", record.ChunkID, *record.BlockID, record.Change)
+	} else if rep.verbose && (len(record.Change) > 0) {
+		fmt.Fprintf(rep.out, "%s: %s\n", record.ChunkID, record.Change)
+	}
+}
+
+// chunkChange reports a change that concerns a chunk as a whole, rather than one of its blocks.
+func (rep *reporter) chunkChange(chunkID chunk.Identifier, contentType chunk.ContentType, format string, a ...interface{}) {
+	rep.block(blockRecord{
+		ChunkID:     formatChunkID(chunkID),
+		ContentType: contentTypeName(contentType),
+		Change:      fmt.Sprintf(format, a...)})
+}
+
+// entry reports a descriptive record, such as a chunk listing. Text mode prints the given line instead.
+func (rep *reporter) entry(record interface{}, line string) {
+	if rep.jsonOutput {
+		rep.encoder.Encode(record)
+	} else {
+		fmt.Fprintf(rep.out, "%s\n", line)
+	}
+}
+
+// warning reports a general problem that does not prevent the operation.
+func (rep *reporter) warning(format string, a ...interface{}) {
+	var record blockRecord
+	record.warn(format, a...)
+	rep.block(record)
+}
+
+// failure reports a general problem that is not bound to a specific block.
+func (rep *reporter) failure(format string, a ...interface{}) {
+	var record blockRecord
+	record.fail(format, a...)
+	rep.block(record)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/inkyblackness/res/chunk"
+	"github.com/inkyblackness/res/chunk/resfile"
+	"github.com/inkyblackness/res/serial"
+)
+
+// loadResourceFile reads the complete resource file into memory and returns
+// a store based on it. As the file is not kept open, it can be overwritten later on.
+func loadResourceFile(fileName string) (store *chunk.ProviderBackedStore, err error) {
+	fileData, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+	return loadResourceData(fileData)
+}
+
+// loadResourceData returns a store based on the given content of a resource file.
+func loadResourceData(fileData []byte) (store *chunk.ProviderBackedStore, err error) {
+	reader, err := resfile.ReaderFrom(bytes.NewReader(fileData))
+	if err != nil {
+		return
+	}
+	store = chunk.NewProviderBackedStore(reader)
+	return
+}
+
+// saveOptions control how a resource file is written.
+type saveOptions struct {
+	// outputFile, if not empty, receives the result instead of the resource file.
+	outputFile string
+	// backup keeps a copy of a replaced file, with ".bak" appended to the file name.
+	backup bool
+	// dryRun only encodes the data, without writing any file.
+	dryRun bool
+}
+
+// saveResourceFile encodes the given provider and writes it to the given file.
+// The data is first written to a temporary file in the same directory, which then
+// replaces the target file only if everything was written successfully.
+func saveResourceFile(fileName string, provider chunk.Provider, options saveOptions) (err error) {
+	buffer := serial.NewByteStore()
+	err = resfile.Write(buffer, provider)
+	if (err != nil) || options.dryRun {
+		return
+	}
+	targetFile := fileName
+	if len(options.outputFile) > 0 {
+		targetFile = options.outputFile
+	}
+	fileMode := os.FileMode(0644)
+	info, statErr := os.Stat(targetFile)
+	if statErr == nil {
+		fileMode = info.Mode()
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(targetFile), "."+filepath.Base(targetFile)+".tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tempFile.Name())
+		}
+	}()
+	_, err = tempFile.Write(buffer.Data())
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempFile.Name(), fileMode)
+	}
+	if (err == nil) && options.backup && (statErr == nil) {
+		err = copyFile(targetFile, targetFile+".bak", fileMode)
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), targetFile)
+	}
+	return
+}
+
+func copyFile(sourceFile string, targetFile string, fileMode os.FileMode) error {
+	data, err := ioutil.ReadFile(sourceFile)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(targetFile, data, fileMode)
+}
+
+// emptyProvider is a provider without any chunks.
+type emptyProvider struct{}
+
+func (provider emptyProvider) IDs() []chunk.Identifier {
+	return nil
+}
+
+func (provider emptyProvider) Chunk(id chunk.Identifier) (*chunk.Chunk, error) {
+	return nil, fmt.Errorf("chunk %04X does not exist", id.Value())
+}
+
+// hasChunk returns true if the provider contains a chunk with given identifier.
+func hasChunk(provider chunk.Provider, id chunk.Identifier) bool {
+	for _, existingID := range provider.IDs() {
+		if existingID.Value() == id.Value() {
+			return true
+		}
+	}
+	return false
+}
+
+// readBlock returns the data of a single block of the chunk with given identifier.
+func readBlock(provider chunk.Provider, chunkID chunk.Identifier, blockID int) ([]byte, error) {
+	if !hasChunk(provider, chunkID) {
+		return nil, fmt.Errorf("chunk %v not found", chunkID)
+	}
+	holder, err := provider.Chunk(chunkID)
+	if err != nil {
+		return nil, err
+	}
+	blockReader, err := holder.Block(blockID)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(blockReader)
+}
+
+// readBlocks returns the data of all blocks of the given holder.
+func readBlocks(holder chunk.BlockProvider) (blocks [][]byte, err error) {
+	blockCount := holder.BlockCount()
+	blocks = make([][]byte, 0, blockCount)
+	for blockID := 0; blockID < blockCount; blockID++ {
+		blockReader, blockErr := holder.Block(blockID)
+		if blockErr != nil {
+			return nil, blockErr
+		}
+		blockData, dataErr := ioutil.ReadAll(blockReader)
+		if dataErr != nil {
+			return nil, dataErr
+		}
+		blocks = append(blocks, blockData)
+	}
+	return
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/inkyblackness/res/chunk"
+	"github.com/inkyblackness/res/text"
+
+	"github.com/inkyblackness/chunkie/convert"
+)
+
+// textLimits restrict the length of the texts of a chunk. Limits of zero are not checked.
+// Lengths are counted in characters, without the terminating zero.
+type textLimits struct {
+	MaxLength     int `json:"maxLength"`
+	MaxLines      int `json:"maxLines"`
+	MaxLineLength int `json:"maxLineLength"`
+}
+
+// textLimitSet holds the limits per chunk, and the limits for all other chunks.
+type textLimitSet struct {
+	chunks   map[uint16]textLimits
+	fallback textLimits
+}
+
+// loadTextLimits reads a JSON file that maps chunk identifiers (e.g. "0x0867") to limits.
+// The limits of the key "default" apply to all chunks that are not listed.
+func loadTextLimits(fileName string) (set textLimitSet, err error) {
+	fileData, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+	var entries map[string]textLimits
+	err = json.Unmarshal(fileData, &entries)
+	if err != nil {
+		return
+	}
+	set.chunks = make(map[uint16]textLimits)
+	for key, limits := range entries {
+		if key == "default" {
+			set.fallback = limits
+			continue
+		}
+		chunkValue, keyErr := strconv.ParseUint(key, 0, 16)
+		if keyErr != nil {
+			return set, fmt.Errorf("invalid chunk identifier <%v>", key)
+		}
+		set.chunks[uint16(chunkValue)] = limits
+	}
+	return
+}
+
+// forChunk returns the limits for the given chunk.
+func (set textLimitSet) forChunk(chunkID chunk.Identifier) textLimits {
+	if limits, listed := set.chunks[chunkID.Value()]; listed {
+		return limits
+	}
+	return set.fallback
+}
+
+// checkText verifies a single text against the limits and the code page, reporting any problem as warning.
+// Returns false if the text would break the game. Characters that are not stored as intended do not make it invalid.
+func checkText(record *blockRecord, blockID int, value string, limits textLimits, cp text.Codepage) bool {
+	valid := true
+	invalid := func(format string, a ...interface{}) {
+		record.warn("Block %d: %v", blockID, fmt.Sprintf(format, a...))
+		valid = false
+	}
+	if strings.ContainsRune(value, 0x00) {
+		invalid("contains a zero character, which terminates the text early")
+	}
+	for _, r := range value {
+		if (r < 0x20) && (r != 0x00) && (r != '\n') {
+			record.warn("Block %d: contains the control character %q", blockID, r)
+			break
+		}
+	}
+	if length := utf8.RuneCountInString(value); (limits.MaxLength > 0) && (length > limits.MaxLength) {
+		invalid("text has %d characters, more than %d", length, limits.MaxLength)
+	}
+	lines := strings.Split(value, "\n")
+	if (limits.MaxLines > 0) && (len(lines) > limits.MaxLines) {
+		invalid("text has %d lines, more than %d", len(lines), limits.MaxLines)
+	}
+	for lineIndex, line := range lines {
+		if length := utf8.RuneCountInString(line); (limits.MaxLineLength > 0) && (length > limits.MaxLineLength) {
+			invalid("line %d has %d characters, more than %d", lineIndex+1, length, limits.MaxLineLength)
+		}
+	}
+	if unencodable := convert.UnencodableRunes(cp, value); len(unencodable) > 0 {
+		record.warn("Block %d: has characters that are not part of the code page: %q", blockID, string(unencodable))
+	}
+	return valid
+}
+
+// encodeTexts checks the given texts and encodes them with the code page.
+// Returns nil, and fails the record, if any text is invalid.
+func encodeTexts(record *blockRecord, texts []string, limits textLimits, cp text.Codepage) [][]byte {
+	invalidCount := 0
+	for blockID, value := range texts {
+		if !checkText(record, blockID, value, limits, cp) {
+			invalidCount++
+		}
+	}
+	if invalidCount > 0 {
+		record.fail("%d of %d texts are invalid, see warnings", invalidCount, len(texts))
+		return nil
+	}
+	return convert.EncodeTexts(cp, texts)
+}
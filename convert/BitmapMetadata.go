@@ -0,0 +1,73 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/inkyblackness/res/image"
+)
+
+// BitmapMetadata describes the header properties of a bitmap that are not part of the image itself.
+type BitmapMetadata struct {
+	Compressed     bool      `json:"compressed"`
+	PrivatePalette bool      `json:"privatePalette"`
+	Flags          uint16    `json:"flags"`
+	Anchor         [4]uint16 `json:"anchor"`
+}
+
+// ReadBitmapMetadata extracts the metadata from the header of given bitmap block data.
+func ReadBitmapMetadata(blockData []byte) (meta BitmapMetadata, err error) {
+	var header image.BitmapHeader
+
+	err = binary.Read(bytes.NewReader(blockData), binary.LittleEndian, &header)
+	if err != nil {
+		return
+	}
+	meta.Compressed = header.Type == image.CompressedBitmap
+	meta.PrivatePalette = header.PaletteOffset != 0
+	meta.Flags = uint16(header.Flags)
+	meta.Anchor = header.HotspotBox
+
+	return
+}
+
+// Header returns the bitmap header described by the metadata, to be used with EncodeImage.
+// The dimensions and the palette offset are determined on encoding and are left zero.
+func (meta BitmapMetadata) Header() image.BitmapHeader {
+	header := image.BitmapHeader{
+		Type:       image.UncompressedBitmap,
+		Flags:      image.BitmapFlag(meta.Flags),
+		HotspotBox: meta.Anchor}
+	if meta.Compressed {
+		header.Type = image.CompressedBitmap
+	}
+	return header
+}
+
+// WriteBitmapMetadata saves the metadata of given bitmap block data as JSON file.
+func WriteBitmapMetadata(fileName string, blockData []byte) (err error) {
+	meta, err := ReadBitmapMetadata(blockData)
+	if err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(&meta, "", "    ")
+	if err != nil {
+		return
+	}
+
+	return ioutil.WriteFile(fileName, data, os.FileMode(0644))
+}
+
+// LoadBitmapMetadata reads metadata from a JSON file.
+func LoadBitmapMetadata(fileName string) (meta BitmapMetadata, err error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &meta)
+
+	return
+}
@@ -0,0 +1,205 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/inkyblackness/res/text"
+)
+
+// codepageTables lists the upper half (0x80-0xFF) of the known code pages. The lower half is ASCII.
+// Bytes without a character are 0.
+var codepageTables = map[string][]rune{
+	"cp437": {
+		0x00C7, 0x00FC, 0x00E9, 0x00E2, 0x00E4, 0x00E0, 0x00E5, 0x00E7,
+		0x00EA, 0x00EB, 0x00E8, 0x00EF, 0x00EE, 0x00EC, 0x00C4, 0x00C5,
+		0x00C9, 0x00E6, 0x00C6, 0x00F4, 0x00F6, 0x00F2, 0x00FB, 0x00F9,
+		0x00FF, 0x00D6, 0x00DC, 0x00A2, 0x00A3, 0x00A5, 0x20A7, 0x0192,
+		0x00E1, 0x00ED, 0x00F3, 0x00FA, 0x00F1, 0x00D1, 0x00AA, 0x00BA,
+		0x00BF, 0x2310, 0x00AC, 0x00BD, 0x00BC, 0x00A1, 0x00AB, 0x00BB,
+		0x2591, 0x2592, 0x2593, 0x2502, 0x2524, 0x2561, 0x2562, 0x2556,
+		0x2555, 0x2563, 0x2551, 0x2557, 0x255D, 0x255C, 0x255B, 0x2510,
+		0x2514, 0x2534, 0x252C, 0x251C, 0x2500, 0x253C, 0x255E, 0x255F,
+		0x255A, 0x2554, 0x2569, 0x2566, 0x2560, 0x2550, 0x256C, 0x2567,
+		0x2568, 0x2564, 0x2565, 0x2559, 0x2558, 0x2552, 0x2553, 0x256B,
+		0x256A, 0x2518, 0x250C, 0x2588, 0x2584, 0x258C, 0x2590, 0x2580,
+		0x03B1, 0x00DF, 0x0393, 0x03C0, 0x03A3, 0x03C3, 0x00B5, 0x03C4,
+		0x03A6, 0x0398, 0x03A9, 0x03B4, 0x221E, 0x03C6, 0x03B5, 0x2229,
+		0x2261, 0x00B1, 0x2265, 0x2264, 0x2320, 0x2321, 0x00F7, 0x2248,
+		0x00B0, 0x2219, 0x00B7, 0x221A, 0x207F, 0x00B2, 0x25A0, 0x00A0,
+	},
+	"cp850": {
+		0x00C7, 0x00FC, 0x00E9, 0x00E2, 0x00E4, 0x00E0, 0x00E5, 0x00E7,
+		0x00EA, 0x00EB, 0x00E8, 0x00EF, 0x00EE, 0x00EC, 0x00C4, 0x00C5,
+		0x00C9, 0x00E6, 0x00C6, 0x00F4, 0x00F6, 0x00F2, 0x00FB, 0x00F9,
+		0x00FF, 0x00D6, 0x00DC, 0x00F8, 0x00A3, 0x00D8, 0x00D7, 0x0192,
+		0x00E1, 0x00ED, 0x00F3, 0x00FA, 0x00F1, 0x00D1, 0x00AA, 0x00BA,
+		0x00BF, 0x00AE, 0x00AC, 0x00BD, 0x00BC, 0x00A1, 0x00AB, 0x00BB,
+		0x2591, 0x2592, 0x2593, 0x2502, 0x2524, 0x00C1, 0x00C2, 0x00C0,
+		0x00A9, 0x2563, 0x2551, 0x2557, 0x255D, 0x00A2, 0x00A5, 0x2510,
+		0x2514, 0x2534, 0x252C, 0x251C, 0x2500, 0x253C, 0x00E3, 0x00C3,
+		0x255A, 0x2554, 0x2569, 0x2566, 0x2560, 0x2550, 0x256C, 0x00A4,
+		0x00F0, 0x00D0, 0x00CA, 0x00CB, 0x00C8, 0x0131, 0x00CD, 0x00CE,
+		0x00CF, 0x2518, 0x250C, 0x2588, 0x2584, 0x00A6, 0x00CC, 0x2580,
+		0x00D3, 0x00DF, 0x00D4, 0x00D2, 0x00F5, 0x00D5, 0x00B5, 0x00FE,
+		0x00DE, 0x00DA, 0x00DB, 0x00D9, 0x00FD, 0x00DD, 0x00AF, 0x00B4,
+		0x00AD, 0x00B1, 0x2017, 0x00BE, 0x00B6, 0x00A7, 0x00F7, 0x00B8,
+		0x00B0, 0x00A8, 0x00B7, 0x00B9, 0x00B3, 0x00B2, 0x25A0, 0x00A0,
+	},
+	"cp1252": {
+		0x20AC, 0x0000, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+		0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x0000, 0x017D, 0x0000,
+		0x0000, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+		0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x0000, 0x017E, 0x0178,
+		0x00A0, 0x00A1, 0x00A2, 0x00A3, 0x00A4, 0x00A5, 0x00A6, 0x00A7,
+		0x00A8, 0x00A9, 0x00AA, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x00AF,
+		0x00B0, 0x00B1, 0x00B2, 0x00B3, 0x00B4, 0x00B5, 0x00B6, 0x00B7,
+		0x00B8, 0x00B9, 0x00BA, 0x00BB, 0x00BC, 0x00BD, 0x00BE, 0x00BF,
+		0x00C0, 0x00C1, 0x00C2, 0x00C3, 0x00C4, 0x00C5, 0x00C6, 0x00C7,
+		0x00C8, 0x00C9, 0x00CA, 0x00CB, 0x00CC, 0x00CD, 0x00CE, 0x00CF,
+		0x00D0, 0x00D1, 0x00D2, 0x00D3, 0x00D4, 0x00D5, 0x00D6, 0x00D7,
+		0x00D8, 0x00D9, 0x00DA, 0x00DB, 0x00DC, 0x00DD, 0x00DE, 0x00DF,
+		0x00E0, 0x00E1, 0x00E2, 0x00E3, 0x00E4, 0x00E5, 0x00E6, 0x00E7,
+		0x00E8, 0x00E9, 0x00EA, 0x00EB, 0x00EC, 0x00ED, 0x00EE, 0x00EF,
+		0x00F0, 0x00F1, 0x00F2, 0x00F3, 0x00F4, 0x00F5, 0x00F6, 0x00F7,
+		0x00F8, 0x00F9, 0x00FA, 0x00FB, 0x00FC, 0x00FD, 0x00FE, 0x00FF,
+	}}
+
+// tableCodepage is a code page based on a table of characters per byte value.
+type tableCodepage struct {
+	runes [256]rune
+	bytes map[rune]byte
+}
+
+func newTableCodepage(runes [256]rune) *tableCodepage {
+	cp := &tableCodepage{runes: runes, bytes: make(map[rune]byte)}
+	for value := len(runes) - 1; value > 0; value-- {
+		if runes[value] != 0 {
+			cp.bytes[runes[value]] = byte(value)
+		}
+	}
+	return cp
+}
+
+// Encode encodes the given string, with a terminating zero. Characters that are not part of the
+// code page are replaced with '?'.
+func (cp *tableCodepage) Encode(value string) []byte {
+	data := make([]byte, 0, len(value)+1)
+	for _, r := range value {
+		encoded, known := cp.bytes[r]
+		if !known {
+			encoded = '?'
+		}
+		data = append(data, encoded)
+	}
+	return append(data, 0x00)
+}
+
+// Decode decodes the given data up to the terminating zero.
+func (cp *tableCodepage) Decode(data []byte) string {
+	var result strings.Builder
+	for _, value := range data {
+		if value == 0x00 {
+			break
+		}
+		r := cp.runes[value]
+		if r == 0 {
+			r = utf8.RuneError
+		}
+		result.WriteRune(r)
+	}
+	return result.String()
+}
+
+// asciiRunes returns a table with the ASCII characters in the lower half.
+func asciiRunes() (runes [256]rune) {
+	for value := 1; value < 0x80; value++ {
+		runes[value] = rune(value)
+	}
+	return
+}
+
+// LoadCodepage returns the code page with the given name. An empty name or "default" refers to the
+// code page of the game. "cp437", "cp850" and "cp1252" are known by name; any other name refers to a mapping file.
+func LoadCodepage(name string) (text.Codepage, error) {
+	lowerName := strings.ToLower(name)
+	if (len(name) == 0) || (lowerName == "default") {
+		return text.DefaultCodepage(), nil
+	}
+	if table, known := codepageTables[lowerName]; known {
+		runes := asciiRunes()
+		copy(runes[0x80:], table)
+		return newTableCodepage(runes), nil
+	}
+	return loadCodepageFile(name)
+}
+
+// loadCodepageFile reads a mapping file with one byte value and character per line, both in hexadecimal,
+// such as "0x80 0x00C7" or "0x80 U+00C7". Text following '#' is ignored.
+// Byte values below 0x80 that are not listed are ASCII.
+func loadCodepageFile(fileName string) (text.Codepage, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	runes := asciiRunes()
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if commentStart := strings.Index(line, "#"); commentStart >= 0 {
+			line = line[:commentStart]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%v:%d: expected byte value and character", fileName, lineNumber)
+		}
+		value, valueErr := strconv.ParseUint(fields[0], 0, 8)
+		if valueErr != nil {
+			return nil, fmt.Errorf("%v:%d: invalid byte value: %v", fileName, lineNumber, valueErr)
+		}
+		if value == 0 {
+			return nil, fmt.Errorf("%v:%d: byte value 0x00 is reserved for the terminator", fileName, lineNumber)
+		}
+		var character uint64
+		var characterErr error
+		if strings.HasPrefix(fields[1], "U+") {
+			character, characterErr = strconv.ParseUint(fields[1][2:], 16, 32)
+		} else {
+			character, characterErr = strconv.ParseUint(fields[1], 0, 32)
+		}
+		if characterErr != nil {
+			return nil, fmt.Errorf("%v:%d: invalid character: %v", fileName, lineNumber, characterErr)
+		}
+		runes[value] = rune(character)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return newTableCodepage(runes), nil
+}
+
+// UnencodableRunes returns the characters of the given value that can not be encoded with the code page,
+// each one listed once.
+func UnencodableRunes(cp text.Codepage, value string) (unencodable []rune) {
+	checked := make(map[rune]bool)
+	for _, r := range value {
+		if checked[r] {
+			continue
+		}
+		checked[r] = true
+		if cp.Decode(cp.Encode(string(r))) != string(r) {
+			unencodable = append(unencodable, r)
+		}
+	}
+	return
+}
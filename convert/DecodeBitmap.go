@@ -0,0 +1,18 @@
+package convert
+
+import (
+	"bytes"
+	goimage "image"
+
+	"github.com/inkyblackness/res/image"
+)
+
+// DecodeBitmap decodes the bitmap of given block data. The palette of the returned image is the private
+// palette of the bitmap, and empty if it has none.
+func DecodeBitmap(blockData []byte) (img *goimage.Paletted, err error) {
+	bitmap, err := image.Read(bytes.NewReader(blockData))
+	if err != nil {
+		return
+	}
+	return image.FromBitmap(bitmap, nil), nil
+}
@@ -2,25 +2,44 @@ package convert
 
 import (
 	"bytes"
+	"encoding/binary"
 	goimage "image"
 
 	"github.com/inkyblackness/res/image"
 )
 
-// EncodeImage takes a paletted image and encodes it as a block.
-func EncodeImage(img *goimage.Paletted, withPrivatePalette bool, compressed, forceTransparency bool) []byte {
+// EncodeImage takes a paletted image and encodes it as a block with the given header.
+// The type, flags and hotspot box are taken from the header; the dimensions are those of the image.
+// The palette is stored with the bitmap only if withPrivatePalette is set.
+func EncodeImage(img *goimage.Paletted, header image.BitmapHeader, withPrivatePalette bool) (data []byte, err error) {
 	palette := img.Palette
-	imgType := image.UncompressedBitmap
 
 	if !withPrivatePalette {
 		palette = nil
 	}
-	if compressed {
-		imgType = image.CompressedBitmap
-	}
 	bmp := image.ToBitmap(img, palette)
 	buf := bytes.NewBuffer(nil)
-	image.Write(buf, bmp, imgType, forceTransparency, 0)
+	image.Write(buf, bmp, header.Type, (header.Flags&image.Transparent) != 0, 0)
+	data = buf.Bytes()
+	err = writeHeaderProperties(data, header)
+
+	return
+}
+
+// writeHeaderProperties sets the flags and the hotspot box of the given header in encoded block data,
+// as image.Write only considers the transparency flag.
+func writeHeaderProperties(blockData []byte, header image.BitmapHeader) (err error) {
+	var written image.BitmapHeader
+
+	err = binary.Read(bytes.NewReader(blockData), binary.LittleEndian, &written)
+	if err != nil {
+		return
+	}
+	written.Flags = header.Flags
+	written.HotspotBox = header.HotspotBox
+	buf := bytes.NewBuffer(nil)
+	err = binary.Write(buf, binary.LittleEndian, &written)
+	copy(blockData, buf.Bytes())
 
-	return buf.Bytes()
+	return
 }
@@ -0,0 +1,6 @@
+package convert
+
+import "errors"
+
+// ErrPaletteRequired is returned if content can not be converted because it requires a palette.
+var ErrPaletteRequired = errors.New("palette required")
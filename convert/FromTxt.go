@@ -0,0 +1,71 @@
+package convert
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/inkyblackness/res/text"
+)
+
+// ReadTxt reads an XML file as written by ToTxt and returns the text of each block.
+// Entries without a block attribute follow the previous entry.
+func ReadTxt(fileName string) (texts []string, err error) {
+	fileData, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+	var decoded Text
+	err = xml.Unmarshal(fileData, &decoded)
+	if err != nil {
+		return
+	}
+
+	entries := make(map[int]string)
+	blockCount := 0
+	nextBlockID := 0
+	for _, entry := range decoded.Entries {
+		blockID := nextBlockID
+		if entry.Block != nil {
+			blockID = *entry.Block
+		}
+		if blockID < 0 {
+			return nil, fmt.Errorf("invalid block %d", blockID)
+		}
+		if _, existing := entries[blockID]; existing {
+			return nil, fmt.Errorf("duplicate entry for block %d", blockID)
+		}
+		entries[blockID] = entry.CData
+		nextBlockID = blockID + 1
+		if nextBlockID > blockCount {
+			blockCount = nextBlockID
+		}
+	}
+	texts = make([]string, blockCount)
+	for blockID := 0; blockID < blockCount; blockID++ {
+		value, existing := entries[blockID]
+		if !existing {
+			return nil, fmt.Errorf("missing entry for block %d", blockID)
+		}
+		texts[blockID] = value
+	}
+	return
+}
+
+// EncodeTexts encodes the given texts with the code page, each with a terminating zero.
+func EncodeTexts(cp text.Codepage, texts []string) [][]byte {
+	blocks := make([][]byte, len(texts))
+	for blockID, value := range texts {
+		blocks[blockID] = encodeText(cp, value)
+	}
+	return blocks
+}
+
+// encodeText encodes the given string with the code page and ensures the terminating zero.
+func encodeText(cp text.Codepage, value string) []byte {
+	data := cp.Encode(value)
+	if (len(data) == 0) || (data[len(data)-1] != 0x00) {
+		data = append(data, 0x00)
+	}
+	return data
+}
@@ -0,0 +1,128 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testBlocks provides the blocks of a chunk from memory.
+type testBlocks [][]byte
+
+func (blocks testBlocks) BlockCount() int {
+	return len(blocks)
+}
+
+func (blocks testBlocks) Block(index int) (io.Reader, error) {
+	if (index < 0) || (index >= len(blocks)) {
+		return nil, fmt.Errorf("block %d does not exist", index)
+	}
+	return bytes.NewReader(blocks[index]), nil
+}
+
+func writeTestFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatalf("writing test file failed: %v", err)
+	}
+	return fileName
+}
+
+func TestReadTxt(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{"entries with block",
+			`<Text><Entry block="0"><![CDATA[first]]></Entry><Entry block="1"><![CDATA[second]]></Entry></Text>`,
+			[]string{"first", "second"}},
+		{"entries without block follow the previous",
+			`<Text><Entry>a</Entry><Entry>b</Entry><Entry>c</Entry></Text>`,
+			[]string{"a", "b", "c"}},
+		{"entries out of order",
+			`<Text><Entry block="2">c</Entry><Entry block="0">a</Entry><Entry>b</Entry></Text>`,
+			[]string{"a", "b", "c"}},
+		{"multi line and special characters",
+			"<Text><Entry><![CDATA[line 1\nline <2> & \"3\"]]></Entry><Entry></Entry></Text>",
+			[]string{"line 1\nline <2> & \"3\"", ""}},
+		{"no entries",
+			`<Text></Text>`,
+			[]string{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			texts, err := ReadTxt(writeTestFile(t, "text.xml", tc.content))
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if !reflect.DeepEqual(texts, tc.expected) {
+				t.Errorf("texts = %q, expected %q", texts, tc.expected)
+			}
+		})
+	}
+}
+
+func TestReadTxtRejectsMalformedInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty file", ""},
+		{"not XML", "first\nsecond"},
+		{"unterminated", `<Text><Entry block="0">a`},
+		{"invalid block", `<Text><Entry block="zero">a</Entry></Text>`},
+		{"negative block", `<Text><Entry block="-1">a</Entry></Text>`},
+		{"duplicate block", `<Text><Entry block="1">a</Entry><Entry block="0">b</Entry><Entry>c</Entry></Text>`},
+		{"missing block", `<Text><Entry block="0">a</Entry><Entry block="2">c</Entry></Text>`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ReadTxt(writeTestFile(t, "text.xml", tc.content)); err == nil {
+				t.Errorf("malformed input was accepted")
+			}
+		})
+	}
+}
+
+func TestTxtRoundTrip(t *testing.T) {
+	cp, _ := LoadCodepage("cp850")
+	tests := []struct {
+		name  string
+		texts []string
+	}{
+		{"single text", []string{"Hello"}},
+		{"several texts", []string{"first", "", "third\nwith two lines"}},
+		{"code page characters", []string{"Größe", "Ça va"}},
+		{"markup characters", []string{"<b>bold</b> & ]]> end"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "text.xml")
+			if err := ToTxt(fileName, testBlocks(EncodeTexts(cp, tc.texts)), cp); err != nil {
+				t.Fatalf("export failed: %v", err)
+			}
+			imported, err := ReadTxt(fileName)
+			if err != nil {
+				t.Fatalf("import failed: %v", err)
+			}
+			if !reflect.DeepEqual(imported, tc.texts) {
+				t.Errorf("texts = %q, expected %q", imported, tc.texts)
+			}
+		})
+	}
+}
+
+func TestEncodeTextsTerminatesBlocks(t *testing.T) {
+	cp, _ := LoadCodepage("cp437")
+	blocks := EncodeTexts(cp, []string{"", "ab"})
+	expected := [][]byte{{0x00}, {'a', 'b', 0x00}}
+	if !reflect.DeepEqual(blocks, expected) {
+		t.Errorf("blocks = %v, expected %v", blocks, expected)
+	}
+}
@@ -0,0 +1,291 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// PaletteSize is the number of colors in a palette chunk.
+const PaletteSize = 256
+
+// PaletteFileExtensions lists the extensions of the palette files written by ToPaletteFiles.
+var PaletteFileExtensions = []string{".pal", ".gpl", ".act", ".swatch.png"}
+
+// EncodePalette encodes the given palette as block data of a palette chunk.
+// Missing entries are filled with black.
+func EncodePalette(palette color.Palette) (data []byte, err error) {
+	if len(palette) > PaletteSize {
+		return nil, fmt.Errorf("palette has %d colors, only %d are supported", len(palette), PaletteSize)
+	}
+	data = make([]byte, PaletteSize*3)
+	for index, entry := range palette {
+		rgba := color.NRGBAModel.Convert(entry).(color.NRGBA)
+		data[index*3+0] = rgba.R
+		data[index*3+1] = rgba.G
+		data[index*3+2] = rgba.B
+	}
+	return
+}
+
+// newPalette creates a palette from RGB triplets. As in the game, the first entry is transparent.
+func newPalette(rgb [][3]byte) color.Palette {
+	palette := make(color.Palette, len(rgb))
+	for index, entry := range rgb {
+		alpha := byte(0xFF)
+		if index == TransparentIndex {
+			alpha = 0x00
+		}
+		palette[index] = color.NRGBA{R: entry[0], G: entry[1], B: entry[2], A: alpha}
+	}
+	return palette
+}
+
+func paletteRGB(palette color.Palette, index int) (r, g, b byte) {
+	rgba := color.NRGBAModel.Convert(palette[index]).(color.NRGBA)
+	return rgba.R, rgba.G, rgba.B
+}
+
+// ToPaletteFiles writes the given palette in all supported palette file formats.
+// The returned list contains the names of the written files.
+func ToPaletteFiles(fileBaseName string, palette color.Palette) (files []string, err error) {
+	writers := map[string]func(io.Writer, color.Palette) error{
+		".pal":        WriteJascPalette,
+		".gpl":        WriteGimpPalette,
+		".act":        WriteActPalette,
+		".swatch.png": WriteSwatchPng}
+
+	for _, extension := range PaletteFileExtensions {
+		fileName := fileBaseName + extension
+		if err = writePaletteFile(fileName, palette, writers[extension]); err != nil {
+			return
+		}
+		files = append(files, fileName)
+	}
+	return
+}
+
+func writePaletteFile(fileName string, palette color.Palette, writer func(io.Writer, color.Palette) error) (err error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	return writer(file, palette)
+}
+
+// IsPaletteFile returns true if the given file name has the extension of a supported palette file.
+func IsPaletteFile(fileName string) bool {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".pal", ".gpl", ".act", ".png":
+		return true
+	}
+	return false
+}
+
+// LoadPaletteFile reads a palette from a file, with the format determined by the extension:
+// ".pal" (JASC-PAL or raw VGA), ".gpl" (GIMP), ".act" (Adobe Color Table) or ".png" (palette of a paletted image).
+func LoadPaletteFile(fileName string) (palette color.Palette, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".pal":
+		return ReadPalPalette(file)
+	case ".gpl":
+		return ReadGimpPalette(file)
+	case ".act":
+		return ReadActPalette(file)
+	case ".png":
+		return ReadPngPalette(file)
+	}
+	return nil, fmt.Errorf("unknown palette file format of %v", fileName)
+}
+
+// WriteJascPalette writes the palette in the JASC-PAL format.
+func WriteJascPalette(writer io.Writer, palette color.Palette) (err error) {
+	buffered := bufio.NewWriter(writer)
+	fmt.Fprintf(buffered, "JASC-PAL\r\n0100\r\n%d\r\n", len(palette))
+	for index := range palette {
+		r, g, b := paletteRGB(palette, index)
+		fmt.Fprintf(buffered, "%d %d %d\r\n", r, g, b)
+	}
+	return buffered.Flush()
+}
+
+// ReadJascPalette reads a palette in the JASC-PAL format.
+func ReadJascPalette(reader io.Reader) (palette color.Palette, err error) {
+	scanner := bufio.NewScanner(reader)
+	var lines []string
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	if (len(lines) < 3) || (lines[0] != "JASC-PAL") {
+		return nil, fmt.Errorf("not a JASC-PAL palette")
+	}
+	var count int
+	if _, err = fmt.Sscan(lines[2], &count); err != nil {
+		return
+	}
+	if (count < 0) || (len(lines) < 3+count) {
+		return nil, fmt.Errorf("JASC-PAL palette has less colors than specified")
+	}
+	rgb := make([][3]byte, count)
+	for index := range rgb {
+		if _, err = fmt.Sscan(lines[3+index], &rgb[index][0], &rgb[index][1], &rgb[index][2]); err != nil {
+			return nil, fmt.Errorf("invalid color %d in JASC-PAL palette: %v", index, err)
+		}
+	}
+	return newPalette(rgb), nil
+}
+
+// ReadPalPalette reads a palette from a .pal file, which is either in the JASC-PAL format
+// or a raw VGA palette of 768 bytes. Raw palettes with only 6-bit values are scaled to 8 bits.
+func ReadPalPalette(reader io.Reader) (palette color.Palette, err error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return
+	}
+	if strings.HasPrefix(string(data), "JASC-PAL") {
+		return ReadJascPalette(bytes.NewReader(data))
+	}
+	if len(data) != PaletteSize*3 {
+		return nil, fmt.Errorf("neither a JASC-PAL nor a raw VGA palette")
+	}
+	sixBit := true
+	for _, value := range data {
+		sixBit = sixBit && (value < 0x40)
+	}
+	rgb := make([][3]byte, PaletteSize)
+	for index := range rgb {
+		for channel := 0; channel < 3; channel++ {
+			value := data[index*3+channel]
+			if sixBit {
+				value = (value << 2) | (value >> 4)
+			}
+			rgb[index][channel] = value
+		}
+	}
+	return newPalette(rgb), nil
+}
+
+// WriteGimpPalette writes the palette in the GIMP palette format.
+func WriteGimpPalette(writer io.Writer, palette color.Palette) (err error) {
+	buffered := bufio.NewWriter(writer)
+	fmt.Fprintf(buffered, "GIMP Palette\nName: chunkie\nColumns: 16\n#\n")
+	for index := range palette {
+		r, g, b := paletteRGB(palette, index)
+		fmt.Fprintf(buffered, "%3d %3d %3d\tIndex %d\n", r, g, b, index)
+	}
+	return buffered.Flush()
+}
+
+// ReadGimpPalette reads a palette in the GIMP palette format.
+func ReadGimpPalette(reader io.Reader) (palette color.Palette, err error) {
+	scanner := bufio.NewScanner(reader)
+	if !scanner.Scan() || (strings.TrimSpace(scanner.Text()) != "GIMP Palette") {
+		return nil, fmt.Errorf("not a GIMP palette")
+	}
+	var rgb [][3]byte
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if (len(line) == 0) || strings.HasPrefix(line, "#") ||
+			strings.HasPrefix(line, "Name:") || strings.HasPrefix(line, "Columns:") {
+			continue
+		}
+		var entry [3]byte
+		if _, err = fmt.Sscan(line, &entry[0], &entry[1], &entry[2]); err != nil {
+			return nil, fmt.Errorf("invalid color %d in GIMP palette: %v", len(rgb), err)
+		}
+		rgb = append(rgb, entry)
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	return newPalette(rgb), nil
+}
+
+// WriteActPalette writes the palette in the Adobe Color Table format.
+// The table contains the number of colors and specifies the first entry as transparent.
+func WriteActPalette(writer io.Writer, palette color.Palette) (err error) {
+	data := make([]byte, PaletteSize*3+4)
+	for index := 0; (index < len(palette)) && (index < PaletteSize); index++ {
+		data[index*3+0], data[index*3+1], data[index*3+2] = paletteRGB(palette, index)
+	}
+	binary.BigEndian.PutUint16(data[PaletteSize*3:], uint16(len(palette)))
+	binary.BigEndian.PutUint16(data[PaletteSize*3+2:], TransparentIndex)
+	_, err = writer.Write(data)
+	return
+}
+
+// ReadActPalette reads a palette in the Adobe Color Table format.
+func ReadActPalette(reader io.Reader) (palette color.Palette, err error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return
+	}
+	if len(data) < PaletteSize*3 {
+		return nil, fmt.Errorf("Adobe Color Table is too short")
+	}
+	count := PaletteSize
+	if len(data) >= PaletteSize*3+2 {
+		if stored := int(binary.BigEndian.Uint16(data[PaletteSize*3:])); (stored > 0) && (stored < PaletteSize) {
+			count = stored
+		}
+	}
+	rgb := make([][3]byte, count)
+	for index := range rgb {
+		copy(rgb[index][:], data[index*3:index*3+3])
+	}
+	return newPalette(rgb), nil
+}
+
+// WriteSwatchPng writes the palette as a 16x16 paletted image, with one pixel per color.
+func WriteSwatchPng(writer io.Writer, palette color.Palette) error {
+	img := image.NewPaletted(image.Rect(0, 0, 16, 16), palette)
+	for index := range img.Pix {
+		if index < len(palette) {
+			img.Pix[index] = byte(index)
+		}
+	}
+	return png.Encode(writer, img)
+}
+
+// ReadPngPalette returns the palette of a paletted PNG image.
+func ReadPngPalette(reader io.Reader) (palette color.Palette, err error) {
+	img, err := png.Decode(reader)
+	if err != nil {
+		return
+	}
+	palettedImg, isPaletted := img.(*image.Paletted)
+	if !isPaletted {
+		return nil, fmt.Errorf("image is not paletted")
+	}
+	rgb := make([][3]byte, len(palettedImg.Palette))
+	for index := range rgb {
+		rgb[index][0], rgb[index][1], rgb[index][2] = paletteRGB(palettedImg.Palette, index)
+	}
+	return newPalette(rgb), nil
+}
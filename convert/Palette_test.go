@@ -0,0 +1,202 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func samePalette(t *testing.T, actual color.Palette, expected color.Palette) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf("palette has %d colors, expected %d", len(actual), len(expected))
+	}
+	for index := range expected {
+		a := color.NRGBAModel.Convert(actual[index]).(color.NRGBA)
+		e := color.NRGBAModel.Convert(expected[index]).(color.NRGBA)
+		if a != e {
+			t.Errorf("color %d = %v, expected %v", index, a, e)
+		}
+	}
+}
+
+func gradientPalette(size int) color.Palette {
+	rgb := make([][3]byte, size)
+	for index := range rgb {
+		rgb[index] = [3]byte{byte(index), byte(255 - index), byte(index * 7)}
+	}
+	return newPalette(rgb)
+}
+
+func TestPaletteFormatsRoundTrip(t *testing.T) {
+	formats := []struct {
+		name  string
+		write func(io.Writer, color.Palette) error
+		read  func(io.Reader) (color.Palette, error)
+	}{
+		{"JASC-PAL", WriteJascPalette, ReadJascPalette},
+		{"JASC-PAL as .pal", WriteJascPalette, ReadPalPalette},
+		{"GIMP", WriteGimpPalette, ReadGimpPalette},
+		{"ACT", WriteActPalette, ReadActPalette},
+		{"swatch PNG", WriteSwatchPng, ReadPngPalette},
+	}
+	sizes := []int{PaletteSize, 16, 1}
+	for _, format := range formats {
+		for _, size := range sizes {
+			palette := gradientPalette(size)
+			t.Run(fmt.Sprintf("%v with %d colors", format.name, size), func(t *testing.T) {
+				buf := bytes.NewBuffer(nil)
+				if err := format.write(buf, palette); err != nil {
+					t.Fatalf("write failed: %v", err)
+				}
+				read, err := format.read(buf)
+				if err != nil {
+					t.Fatalf("read failed: %v", err)
+				}
+				samePalette(t, read, palette)
+			})
+		}
+	}
+}
+
+func TestToPaletteFilesCanBeLoaded(t *testing.T) {
+	palette := gradientPalette(PaletteSize)
+	files, err := ToPaletteFiles(filepath.Join(t.TempDir(), "palette"), palette)
+	if err != nil {
+		t.Fatalf("writing palette files failed: %v", err)
+	}
+	if len(files) != len(PaletteFileExtensions) {
+		t.Fatalf("written files = %v, expected one per extension", files)
+	}
+	for _, fileName := range files {
+		t.Run(filepath.Base(fileName), func(t *testing.T) {
+			if !IsPaletteFile(fileName) {
+				t.Errorf("written file is not recognized as palette file")
+			}
+			loaded, loadErr := LoadPaletteFile(fileName)
+			if loadErr != nil {
+				t.Fatalf("loading failed: %v", loadErr)
+			}
+			samePalette(t, loaded, palette)
+		})
+	}
+}
+
+func TestEncodePalette(t *testing.T) {
+	data, err := EncodePalette(newPalette([][3]byte{{1, 2, 3}, {4, 5, 6}}))
+	if err != nil {
+		t.Fatalf("encoding failed: %v", err)
+	}
+	if len(data) != PaletteSize*3 {
+		t.Fatalf("data has %d bytes, expected %d", len(data), PaletteSize*3)
+	}
+	if !bytes.Equal(data[:9], []byte{1, 2, 3, 4, 5, 6, 0, 0, 0}) {
+		t.Errorf("data starts with %v", data[:9])
+	}
+	if _, err = EncodePalette(gradientPalette(PaletteSize + 1)); err == nil {
+		t.Errorf("palette with too many colors was accepted")
+	}
+}
+
+func rawVgaPalette(first [3]byte) []byte {
+	data := make([]byte, PaletteSize*3)
+	copy(data[3:], first[:])
+	return data
+}
+
+func pngData(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("encoding test image failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestPaletteReadersGolden(t *testing.T) {
+	pngPalette := color.Palette{color.NRGBA{R: 1, G: 2, B: 3, A: 0xFF}, color.NRGBA{R: 4, G: 5, B: 6, A: 0x80}}
+	tests := []struct {
+		name     string
+		read     func(io.Reader) (color.Palette, error)
+		data     []byte
+		expected color.Palette
+	}{
+		{"JASC-PAL", ReadJascPalette, []byte("JASC-PAL\r\n0100\r\n2\r\n10 20 30\r\n40 50 60\r\n"),
+			newPalette([][3]byte{{10, 20, 30}, {40, 50, 60}})},
+		{"JASC-PAL with extra lines", ReadPalPalette, []byte("JASC-PAL\n0100\n1\n\n7 8 9\n1 1 1\n"),
+			newPalette([][3]byte{{7, 8, 9}})},
+		{"GIMP", ReadGimpPalette, []byte("GIMP Palette\nName: test\nColumns: 16\n#\n  1   2   3\tIndex 0\n4 5 6\n"),
+			newPalette([][3]byte{{1, 2, 3}, {4, 5, 6}})},
+		{"raw VGA with 6-bit values", ReadPalPalette, rawVgaPalette([3]byte{0x3F, 0x20, 0x01}),
+			newPalette(append([][3]byte{{0, 0, 0}, {0xFF, 0x82, 0x04}}, make([][3]byte, PaletteSize-2)...))},
+		{"raw VGA with 8-bit values", ReadPalPalette, rawVgaPalette([3]byte{0x80, 0x20, 0x01}),
+			newPalette(append([][3]byte{{0, 0, 0}, {0x80, 0x20, 0x01}}, make([][3]byte, PaletteSize-2)...))},
+		{"ACT without count", ReadActPalette, rawVgaPalette([3]byte{0x80, 0x20, 0x01}),
+			newPalette(append([][3]byte{{0, 0, 0}, {0x80, 0x20, 0x01}}, make([][3]byte, PaletteSize-2)...))},
+		{"ACT with count", ReadActPalette, append(rawVgaPalette([3]byte{9, 8, 7}), 0x00, 0x02, 0x00, 0x00),
+			newPalette([][3]byte{{0, 0, 0}, {9, 8, 7}})},
+		{"paletted PNG", ReadPngPalette, pngData(t, image.NewPaletted(image.Rect(0, 0, 1, 1), pngPalette)),
+			newPalette([][3]byte{{1, 2, 3}, {4, 5, 6}})},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			palette, err := tc.read(bytes.NewReader(tc.data))
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			samePalette(t, palette, tc.expected)
+		})
+	}
+}
+
+func TestPaletteReadersRejectMalformedInput(t *testing.T) {
+	tests := []struct {
+		name string
+		read func(io.Reader) (color.Palette, error)
+		data []byte
+	}{
+		{"JASC-PAL empty", ReadJascPalette, nil},
+		{"JASC-PAL wrong header", ReadJascPalette, []byte("JASC\n0100\n1\n1 2 3\n")},
+		{"JASC-PAL invalid count", ReadJascPalette, []byte("JASC-PAL\n0100\nmany\n1 2 3\n")},
+		{"JASC-PAL negative count", ReadJascPalette, []byte("JASC-PAL\n0100\n-1\n")},
+		{"JASC-PAL missing colors", ReadJascPalette, []byte("JASC-PAL\n0100\n3\n1 2 3\n")},
+		{"JASC-PAL incomplete color", ReadJascPalette, []byte("JASC-PAL\n0100\n1\n1 2\n")},
+		{"JASC-PAL value out of range", ReadJascPalette, []byte("JASC-PAL\n0100\n1\n300 2 3\n")},
+		{"pal neither format", ReadPalPalette, []byte{1, 2, 3}},
+		{"pal empty", ReadPalPalette, nil},
+		{"GIMP empty", ReadGimpPalette, nil},
+		{"GIMP wrong header", ReadGimpPalette, []byte("Palette\n1 2 3\n")},
+		{"GIMP invalid color", ReadGimpPalette, []byte("GIMP Palette\nred green blue\n")},
+		{"GIMP value out of range", ReadGimpPalette, []byte("GIMP Palette\n1 2 256\n")},
+		{"ACT too short", ReadActPalette, make([]byte, PaletteSize*3-1)},
+		{"PNG garbage", ReadPngPalette, []byte("not an image")},
+		{"PNG truncated", ReadPngPalette, pngData(t, image.NewPaletted(image.Rect(0, 0, 4, 4), gradientPalette(4)))[:40]},
+		{"PNG not paletted", ReadPngPalette, pngData(t, image.NewNRGBA(image.Rect(0, 0, 1, 1)))},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.read(bytes.NewReader(tc.data)); err == nil {
+				t.Errorf("malformed input was accepted")
+			}
+		})
+	}
+}
+
+func TestLoadPaletteFileRejectsUnknownFiles(t *testing.T) {
+	dir := t.TempDir()
+	unknown := filepath.Join(dir, "palette.txt")
+	if err := ioutil.WriteFile(unknown, []byte("JASC-PAL\n0100\n0\n"), 0644); err != nil {
+		t.Fatalf("writing test file failed: %v", err)
+	}
+	for _, fileName := range []string{unknown, filepath.Join(dir, "missing.pal")} {
+		if _, err := LoadPaletteFile(fileName); err == nil {
+			t.Errorf("%v was accepted", filepath.Base(fileName))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package convert
+
+import (
+	"image"
+	"image/color"
+)
+
+// TransparentIndex is the palette index of the transparent color.
+const TransparentIndex = 0
+
+// Quantize maps an arbitrary image onto the given palette, using the nearest color for each pixel.
+// Pixels that are more than half transparent are mapped to the transparent index. Opaque pixels
+// are never mapped to the transparent index. If dither is set, the difference to the mapped color is
+// distributed to the neighbouring pixels using Floyd-Steinberg dithering.
+// The returned count specifies how many pixels did not have an exact match in the palette.
+func Quantize(img image.Image, palette color.Palette, dither bool) (result *image.Paletted, changedPixels int) {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+	result = image.NewPaletted(image.Rect(0, 0, width, height), palette)
+	matcher := newColorMatcher(palette)
+	// The error rows hold the accumulated quantization error, multiplied by 16, with one column padding on each side.
+	currentErrors := make([][3]int32, width+2)
+	nextErrors := make([][3]int32, width+2)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			original := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			if original.A < 0x80 {
+				result.SetColorIndex(x, y, TransparentIndex)
+				if original.A != 0 {
+					changedPixels++
+				}
+				continue
+			}
+			wanted := [3]int32{int32(original.R), int32(original.G), int32(original.B)}
+			if dither {
+				for channel := 0; channel < 3; channel++ {
+					wanted[channel] = clampChannel(wanted[channel] + currentErrors[x+1][channel]/16)
+				}
+			}
+			index := matcher.nearest(wanted)
+			matched := matcher.colors[index]
+			result.SetColorIndex(x, y, index)
+			if (original.A != 0xFF) || (matched[0] != int32(original.R)) ||
+				(matched[1] != int32(original.G)) || (matched[2] != int32(original.B)) {
+				changedPixels++
+			}
+			if dither {
+				for channel := 0; channel < 3; channel++ {
+					diff := wanted[channel] - matched[channel]
+					currentErrors[x+2][channel] += diff * 7
+					nextErrors[x][channel] += diff * 3
+					nextErrors[x+1][channel] += diff * 5
+					nextErrors[x+2][channel] += diff
+				}
+			}
+		}
+		currentErrors, nextErrors = nextErrors, currentErrors
+		for index := range nextErrors {
+			nextErrors[index] = [3]int32{}
+		}
+	}
+
+	return
+}
+
+func clampChannel(value int32) int32 {
+	if value < 0 {
+		return 0
+	}
+	if value > 0xFF {
+		return 0xFF
+	}
+	return value
+}
+
+// colorMatcher finds the nearest opaque palette entry for colors.
+type colorMatcher struct {
+	colors [][3]int32
+	cache  map[[3]int32]uint8
+}
+
+func newColorMatcher(palette color.Palette) *colorMatcher {
+	// An empty palette is treated as having only the transparent entry, so that matching never fails.
+	colorCount := len(palette)
+	if colorCount <= TransparentIndex {
+		colorCount = TransparentIndex + 1
+	}
+	matcher := &colorMatcher{
+		colors: make([][3]int32, colorCount),
+		cache:  make(map[[3]int32]uint8)}
+
+	for index, entry := range palette {
+		rgba := color.NRGBAModel.Convert(entry).(color.NRGBA)
+		matcher.colors[index] = [3]int32{int32(rgba.R), int32(rgba.G), int32(rgba.B)}
+	}
+	return matcher
+}
+
+func (matcher *colorMatcher) nearest(wanted [3]int32) uint8 {
+	if index, cached := matcher.cache[wanted]; cached {
+		return index
+	}
+	bestIndex := 0
+	bestDistance := int32(-1)
+	for index := TransparentIndex + 1; index < len(matcher.colors); index++ {
+		distance := int32(0)
+		for channel := 0; channel < 3; channel++ {
+			diff := wanted[channel] - matcher.colors[index][channel]
+			distance += diff * diff
+		}
+		if (bestDistance < 0) || (distance < bestDistance) {
+			bestIndex = index
+			bestDistance = distance
+		}
+	}
+	matcher.cache[wanted] = uint8(bestIndex)
+	return uint8(bestIndex)
+}
@@ -0,0 +1,123 @@
+package convert
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testPalette returns a palette with the transparent entry followed by opaque black, white, red and gray.
+func testPalette() color.Palette {
+	return newPalette([][3]byte{{0, 0, 0}, {0, 0, 0}, {255, 255, 255}, {255, 0, 0}, {128, 128, 128}})
+}
+
+func uniformImage(width, height int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestQuantizeMapsSinglePixels(t *testing.T) {
+	tests := []struct {
+		name          string
+		pixel         color.NRGBA
+		expectedIndex uint8
+		changed       int
+	}{
+		{"exact white", color.NRGBA{R: 255, G: 255, B: 255, A: 255}, 2, 0},
+		{"exact gray", color.NRGBA{R: 128, G: 128, B: 128, A: 255}, 4, 0},
+		{"nearest red", color.NRGBA{R: 250, G: 10, B: 10, A: 255}, 3, 1},
+		{"opaque black avoids transparent index", color.NRGBA{A: 255}, 1, 0},
+		{"fully transparent", color.NRGBA{R: 255, A: 0}, TransparentIndex, 0},
+		{"mostly transparent", color.NRGBA{R: 255, A: 0x40}, TransparentIndex, 1},
+		{"mostly opaque", color.NRGBA{R: 255, A: 0xC0}, 3, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, changed := Quantize(uniformImage(1, 1, tc.pixel), testPalette(), false)
+			if index := result.ColorIndexAt(0, 0); index != tc.expectedIndex {
+				t.Errorf("index = %d, expected %d", index, tc.expectedIndex)
+			}
+			if changed != tc.changed {
+				t.Errorf("changed = %d, expected %d", changed, tc.changed)
+			}
+		})
+	}
+}
+
+func TestQuantizeKeepsImageGeometry(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(10, 20, 12, 22))
+	img.Set(10, 20, color.NRGBA{R: 255, A: 255})
+	img.Set(11, 20, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	img.Set(10, 21, color.NRGBA{R: 128, G: 128, B: 128, A: 255})
+	img.Set(11, 21, color.NRGBA{A: 255})
+
+	result, changed := Quantize(img, testPalette(), false)
+	if result.Rect != image.Rect(0, 0, 2, 2) {
+		t.Fatalf("bounds = %v, expected origin based 2x2", result.Rect)
+	}
+	if expected := []uint8{3, 2, 4, 1}; string(result.Pix) != string(expected) {
+		t.Errorf("pixels = %v, expected %v", result.Pix, expected)
+	}
+	if changed != 0 {
+		t.Errorf("changed = %d, expected 0", changed)
+	}
+}
+
+func TestQuantizeDithering(t *testing.T) {
+	palette := newPalette([][3]byte{{0, 0, 0}, {0, 0, 0}, {255, 255, 255}})
+	img := uniformImage(16, 16, color.NRGBA{R: 64, G: 64, B: 64, A: 255})
+	tests := []struct {
+		name     string
+		dither   bool
+		minWhite int
+		maxWhite int
+	}{
+		{"nearest color only", false, 0, 0},
+		{"error diffusion", true, 256 * 15 / 100, 256 * 35 / 100},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, changed := Quantize(img, palette, tc.dither)
+			white := 0
+			for _, index := range result.Pix {
+				if index == TransparentIndex {
+					t.Fatalf("opaque pixel mapped to transparent index")
+				}
+				if index == 2 {
+					white++
+				}
+			}
+			if (white < tc.minWhite) || (white > tc.maxWhite) {
+				t.Errorf("white pixels = %d, expected between %d and %d", white, tc.minWhite, tc.maxWhite)
+			}
+			if changed != len(result.Pix) {
+				t.Errorf("changed = %d, expected all %d pixels", changed, len(result.Pix))
+			}
+		})
+	}
+}
+
+func TestQuantizeWithDegeneratePalettes(t *testing.T) {
+	tests := []struct {
+		name    string
+		palette color.Palette
+	}{
+		{"only transparent entry", newPalette([][3]byte{{0, 0, 0}})},
+		{"no entries", color.Palette{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, _ := Quantize(uniformImage(2, 2, color.NRGBA{R: 10, A: 255}), tc.palette, true)
+			for _, index := range result.Pix {
+				if index != TransparentIndex {
+					t.Errorf("index = %d, expected %d", index, TransparentIndex)
+				}
+			}
+		})
+	}
+}
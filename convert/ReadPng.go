@@ -0,0 +1,18 @@
+package convert
+
+import (
+	"image"
+	"image/png"
+	"os"
+)
+
+// ReadPng reads the image of a PNG file.
+func ReadPng(fileName string) (img image.Image, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	return png.Decode(file)
+}
@@ -0,0 +1,55 @@
+package convert
+
+import (
+	"image"
+	"image/color"
+)
+
+// RemapPalette maps the pixels of a paletted image onto the given palette by color, instead of by index.
+// Indices with a color that exists in both palettes keep their index if possible, otherwise the exact match
+// is used. The transparent index, as well as fully transparent colors, map to the transparent index.
+// Colors without an exact match are mapped to the nearest color. Only colors used in the image are considered.
+// The returned count specifies how many of the used colors did not have an exact match.
+func RemapPalette(img *image.Paletted, palette color.Palette) (result *image.Paletted, unmatchedColors int) {
+	matcher := newColorMatcher(palette)
+	exact := make(map[[3]int32]uint8)
+	for index := len(matcher.colors) - 1; index > TransparentIndex; index-- {
+		exact[matcher.colors[index]] = uint8(index)
+	}
+	var used [256]bool
+	for _, index := range img.Pix {
+		used[index] = true
+	}
+
+	var mapping [256]uint8
+	identical := true
+	for index, entry := range img.Palette {
+		if !used[index] {
+			continue
+		}
+		rgba := color.NRGBAModel.Convert(entry).(color.NRGBA)
+		wanted := [3]int32{int32(rgba.R), int32(rgba.G), int32(rgba.B)}
+		if (index == TransparentIndex) || (rgba.A == 0) {
+			mapping[index] = TransparentIndex
+		} else if (index < len(matcher.colors)) && (matcher.colors[index] == wanted) {
+			mapping[index] = uint8(index)
+		} else if exactIndex, found := exact[wanted]; found {
+			mapping[index] = exactIndex
+		} else {
+			mapping[index] = matcher.nearest(wanted)
+			unmatchedColors++
+		}
+		identical = identical && (mapping[index] == uint8(index))
+	}
+
+	result = image.NewPaletted(img.Rect, palette)
+	for offset, index := range img.Pix {
+		if identical {
+			result.Pix[offset] = index
+		} else {
+			result.Pix[offset] = mapping[index]
+		}
+	}
+
+	return
+}
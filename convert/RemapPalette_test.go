@@ -0,0 +1,65 @@
+package convert
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRemapPalette(t *testing.T) {
+	red := [3]byte{255, 0, 0}
+	green := [3]byte{0, 255, 0}
+	blue := [3]byte{0, 0, 255}
+	black := [3]byte{0, 0, 0}
+	tests := []struct {
+		name      string
+		source    color.Palette
+		target    color.Palette
+		pix       []uint8
+		expected  []uint8
+		unmatched int
+	}{
+		{"identical palettes keep indices",
+			newPalette([][3]byte{black, red, green}), newPalette([][3]byte{black, red, green}),
+			[]uint8{0, 1, 2, 1}, []uint8{0, 1, 2, 1}, 0},
+		{"reordered colors follow the color",
+			newPalette([][3]byte{black, red, green}), newPalette([][3]byte{black, green, red}),
+			[]uint8{1, 2, 0, 1}, []uint8{2, 1, 0, 2}, 0},
+		{"duplicate colors keep their index",
+			newPalette([][3]byte{black, red, red}), newPalette([][3]byte{black, red, red}),
+			[]uint8{2, 1}, []uint8{2, 1}, 0},
+		{"missing color is mapped to nearest",
+			newPalette([][3]byte{black, {250, 10, 10}}), newPalette([][3]byte{black, blue, red}),
+			[]uint8{1, 1}, []uint8{2, 2}, 1},
+		{"unused colors are not considered",
+			newPalette([][3]byte{black, red, {1, 2, 3}}), newPalette([][3]byte{black, red}),
+			[]uint8{1, 0}, []uint8{1, 0}, 0},
+		{"opaque black is not mapped to the transparent index",
+			newPalette([][3]byte{black, red, black}), newPalette([][3]byte{black, red, black}),
+			[]uint8{2, 1}, []uint8{2, 1}, 0},
+		{"fully transparent colors map to the transparent index",
+			color.Palette{color.NRGBA{}, color.NRGBA{R: 255, A: 255}, color.NRGBA{G: 255, A: 0}},
+			newPalette([][3]byte{black, red, green}),
+			[]uint8{2, 1}, []uint8{0, 1}, 0},
+		{"empty target palette",
+			newPalette([][3]byte{black, red}), color.Palette{},
+			[]uint8{1, 0}, []uint8{0, 0}, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img := image.NewPaletted(image.Rect(0, 0, len(tc.pix), 1), tc.source)
+			copy(img.Pix, tc.pix)
+
+			result, unmatched := RemapPalette(img, tc.target)
+			if string(result.Pix) != string(tc.expected) {
+				t.Errorf("pixels = %v, expected %v", result.Pix, tc.expected)
+			}
+			if unmatched != tc.unmatched {
+				t.Errorf("unmatched = %d, expected %d", unmatched, tc.unmatched)
+			}
+			if len(result.Palette) != len(tc.target) {
+				t.Errorf("result does not use the target palette")
+			}
+		})
+	}
+}
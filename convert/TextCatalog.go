@@ -0,0 +1,381 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/inkyblackness/res/chunk"
+	"github.com/inkyblackness/res/text"
+)
+
+// TextCatalogExtensions lists the file extensions of the supported translation catalogs.
+var TextCatalogExtensions = []string{".po", ".csv"}
+
+// CatalogEntry is a translatable string of a text chunk.
+type CatalogEntry struct {
+	ChunkID     uint16
+	BlockID     int
+	Source      string
+	Translation string
+	// Fuzzy marks a translation that needs review, as flagged with "#, fuzzy" in gettext catalogs.
+	Fuzzy bool
+}
+
+// context returns the key of the entry, as used for msgctxt.
+func (entry *CatalogEntry) context() string {
+	return fmt.Sprintf("0x%04X/%d", entry.ChunkID, entry.BlockID)
+}
+
+// parseCatalogContext parses a key as returned by context().
+func parseCatalogContext(context string) (chunkID uint16, blockID int, err error) {
+	parts := strings.Split(context, "/")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid context <%v>, expected chunk/block", context)
+	}
+	return parseCatalogKey(parts[0], parts[1])
+}
+
+func parseCatalogKey(chunkText string, blockText string) (chunkID uint16, blockID int, err error) {
+	chunkValue, err := strconv.ParseUint(strings.TrimSpace(chunkText), 0, 16)
+	if err != nil {
+		return
+	}
+	blockValue, err := strconv.ParseUint(strings.TrimSpace(blockText), 0, 16)
+	if err != nil {
+		return
+	}
+	return uint16(chunkValue), int(blockValue), nil
+}
+
+// TextCatalog returns one entry per block of the given text chunk, decoded with the code page.
+// Without a reference, the texts are the source of the entries, which are not translated.
+// With a reference, which is the same chunk in another language, the texts of the reference are the source
+// and the texts of the holder are the translation. The reference is decoded with its own code page.
+func TextCatalog(chunkID uint16, holder chunk.BlockProvider, cp text.Codepage,
+	reference chunk.BlockProvider, referenceCp text.Codepage) (entries []CatalogEntry, err error) {
+	texts, err := DecodeTexts(holder, cp)
+	if err != nil {
+		return
+	}
+	var referenceTexts []string
+	if reference != nil {
+		referenceTexts, err = DecodeTexts(reference, referenceCp)
+		if err != nil {
+			return
+		}
+	}
+	for blockID, value := range texts {
+		entry := CatalogEntry{ChunkID: chunkID, BlockID: blockID, Source: value}
+		if reference != nil {
+			entry.Source = ""
+			entry.Translation = value
+			if blockID < len(referenceTexts) {
+				entry.Source = referenceTexts[blockID]
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// CatalogMerge describes the outcome of merging a catalog into a text chunk.
+type CatalogMerge struct {
+	// Translated is the number of blocks that received a translation.
+	Translated int
+	// Untranslated lists the blocks without a translation, which were kept.
+	Untranslated []int
+	// Stale lists the keys of entries that were not applied, as they do not fit the chunk.
+	Stale []string
+	// Fuzzy lists the keys of entries that were not applied, as their translation is marked fuzzy.
+	Fuzzy []string
+}
+
+// MergeTextCatalog applies the translations of the entries to the texts of a text chunk and returns the result.
+// Entries for other chunks or for blocks beyond the chunk are stale. If reference texts are given, entries
+// whose source differs from the reference are stale as well, as the source was changed after translation.
+// Fuzzy translations are not applied either.
+func MergeTextCatalog(chunkID uint16, texts []string, referenceTexts []string,
+	entries []CatalogEntry) (merged []string, merge CatalogMerge) {
+	merged = append([]string(nil), texts...)
+	translated := make([]bool, len(texts))
+	for _, entry := range entries {
+		if (entry.ChunkID != chunkID) || (entry.BlockID >= len(texts)) {
+			merge.Stale = append(merge.Stale, entry.context())
+		} else if (referenceTexts != nil) && ((entry.BlockID >= len(referenceTexts)) || (referenceTexts[entry.BlockID] != entry.Source)) {
+			merge.Stale = append(merge.Stale, entry.context())
+		} else if entry.Fuzzy {
+			merge.Fuzzy = append(merge.Fuzzy, entry.context())
+		} else if len(entry.Translation) > 0 {
+			merged[entry.BlockID] = entry.Translation
+			translated[entry.BlockID] = true
+		}
+	}
+	for blockID := range merged {
+		if translated[blockID] {
+			merge.Translated++
+		} else {
+			merge.Untranslated = append(merge.Untranslated, blockID)
+		}
+	}
+	return
+}
+
+// ToTextCatalog writes the entries to a translation catalog. The format is determined by the extension of the
+// file name: .po for gettext, .csv for comma separated values.
+func ToTextCatalog(fileName string, entries []CatalogEntry) (err error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	if strings.ToLower(path.Ext(fileName)) == ".csv" {
+		return writeCsvCatalog(file, entries)
+	}
+	return writePoCatalog(file, entries)
+}
+
+// ReadTextCatalog reads the entries of a translation catalog as written by ToTextCatalog.
+func ReadTextCatalog(fileName string) (entries []CatalogEntry, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	if strings.ToLower(path.Ext(fileName)) == ".csv" {
+		return readCsvCatalog(file)
+	}
+	return readPoCatalog(file)
+}
+
+var csvCatalogHeader = []string{"chunk", "block", "source", "translation"}
+
+func writeCsvCatalog(file *os.File, entries []CatalogEntry) error {
+	writer := csv.NewWriter(file)
+	writer.Write(csvCatalogHeader)
+	for _, entry := range entries {
+		writer.Write([]string{fmt.Sprintf("0x%04X", entry.ChunkID), fmt.Sprintf("%d", entry.BlockID), entry.Source, entry.Translation})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func readCsvCatalog(file *os.File) (entries []CatalogEntry, err error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = len(csvCatalogHeader)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return
+	}
+	for index, record := range records {
+		if (index == 0) && (record[0] == csvCatalogHeader[0]) {
+			continue
+		}
+		chunkID, blockID, keyErr := parseCatalogKey(record[0], record[1])
+		if keyErr != nil {
+			return nil, fmt.Errorf("line %d: %v", index+1, keyErr)
+		}
+		entries = append(entries, CatalogEntry{ChunkID: chunkID, BlockID: blockID, Source: record[2], Translation: record[3]})
+	}
+	return
+}
+
+// quotePo quotes the given value as a string of a gettext catalog.
+func quotePo(value string) string {
+	var result strings.Builder
+	result.WriteByte('"')
+	for _, r := range value {
+		switch {
+		case r == '"':
+			result.WriteString(`\"`)
+		case r == '\\':
+			result.WriteString(`\\`)
+		case r == '\n':
+			result.WriteString(`\n`)
+		case r == '\t':
+			result.WriteString(`\t`)
+		case r < 0x20:
+			fmt.Fprintf(&result, `\x%02X`, r)
+		default:
+			result.WriteRune(r)
+		}
+	}
+	result.WriteByte('"')
+	return result.String()
+}
+
+// poEscapes maps the characters after a backslash to the escaped character, as written by gettext.
+var poEscapes = map[byte]byte{
+	'"': '"', '\\': '\\', '\'': '\'', '?': '?',
+	'n': '\n', 't': '\t', 'r': '\r', 'a': '\a', 'b': '\b', 'f': '\f', 'v': '\v'}
+
+// unquotePo returns the value of a string of a gettext catalog, as quoted by quotePo or the gettext tools.
+// These use the escape sequences of C: a character as in poEscapes, \x with up to two hexadecimal digits
+// or a backslash with up to three octal digits. Any other escape sequence is rejected.
+func unquotePo(quoted string) (string, error) {
+	if (len(quoted) < 2) || (quoted[0] != '"') || (quoted[len(quoted)-1] != '"') {
+		return "", fmt.Errorf("string is not quoted")
+	}
+	quoted = quoted[1 : len(quoted)-1]
+	var result strings.Builder
+	for index := 0; index < len(quoted); index++ {
+		c := quoted[index]
+		if c == '"' {
+			return "", fmt.Errorf("unescaped quote at %d", index)
+		}
+		if c != '\\' {
+			result.WriteByte(c)
+			continue
+		}
+		index++
+		if index >= len(quoted) {
+			return "", fmt.Errorf("incomplete escape sequence at the end")
+		}
+		c = quoted[index]
+		if escaped, known := poEscapes[c]; known {
+			result.WriteByte(escaped)
+			continue
+		}
+		digits, base, maxDigits := "", 8, 3
+		if c == 'x' {
+			base, maxDigits = 16, 2
+			index++
+		}
+		for (index < len(quoted)) && (len(digits) < maxDigits) && isDigitOf(quoted[index], base) {
+			digits += quoted[index : index+1]
+			index++
+		}
+		index--
+		value, parseErr := strconv.ParseUint(digits, base, 8)
+		if (len(digits) == 0) || (parseErr != nil) {
+			return "", fmt.Errorf("invalid escape sequence \\%c", c)
+		}
+		result.WriteByte(byte(value))
+	}
+	return result.String(), nil
+}
+
+func isDigitOf(c byte, base int) bool {
+	switch {
+	case (c >= '0') && (c <= '7'):
+		return true
+	case base == 8:
+		return false
+	case (c >= '8') && (c <= '9'):
+		return true
+	default:
+		return ((c >= 'a') && (c <= 'f')) || ((c >= 'A') && (c <= 'F'))
+	}
+}
+
+// isFuzzyFlag returns whether the given flag comment ("#, ...") contains the fuzzy flag.
+func isFuzzyFlag(line string) bool {
+	for _, flag := range strings.Split(strings.TrimPrefix(line, "#,"), ",") {
+		if strings.TrimSpace(flag) == "fuzzy" {
+			return true
+		}
+	}
+	return false
+}
+
+func writePoCatalog(file *os.File, entries []CatalogEntry) error {
+	writer := bufio.NewWriter(file)
+	fmt.Fprintf(writer, "msgid \"\"\nmsgstr %s\n", quotePo("Content-Type: text/plain; charset=UTF-8\n"))
+	for _, entry := range entries {
+		if entry.Fuzzy {
+			fmt.Fprintf(writer, "\n#, fuzzy")
+		}
+		fmt.Fprintf(writer, "\nmsgctxt %s\nmsgid %s\nmsgstr %s\n", quotePo(entry.context()), quotePo(entry.Source), quotePo(entry.Translation))
+	}
+	return writer.Flush()
+}
+
+func readPoCatalog(file *os.File) (entries []CatalogEntry, err error) {
+	var context, source, translation *string
+	var current *string
+	fuzzy := false
+	flush := func() error {
+		if (context == nil) && (source == nil) && (translation == nil) {
+			return nil
+		}
+		if (context != nil) && (source != nil) && (translation != nil) {
+			chunkID, blockID, keyErr := parseCatalogContext(*context)
+			if keyErr != nil {
+				return keyErr
+			}
+			entries = append(entries, CatalogEntry{ChunkID: chunkID, BlockID: blockID,
+				Source: *source, Translation: *translation, Fuzzy: fuzzy})
+		}
+		context, source, translation, current, fuzzy = nil, nil, nil, nil, false
+		return nil
+	}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() && (err == nil) {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			// Comments precede the entry they belong to, which means the previous one is complete.
+			if translation != nil {
+				err = flush()
+			}
+			if strings.HasPrefix(line, "#,") && isFuzzyFlag(line) {
+				fuzzy = true
+			}
+			continue
+		}
+		keyword := ""
+		quoted := line
+		if !strings.HasPrefix(line, `"`) {
+			parts := strings.SplitN(line, " ", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("line %d: invalid entry", lineNumber)
+			}
+			keyword, quoted = parts[0], strings.TrimSpace(parts[1])
+		}
+		value, unquoteErr := unquotePo(quoted)
+		if unquoteErr != nil {
+			return nil, fmt.Errorf("line %d: invalid string: %v", lineNumber, unquoteErr)
+		}
+		switch keyword {
+		case "":
+			if current == nil {
+				return nil, fmt.Errorf("line %d: string without keyword", lineNumber)
+			}
+			*current += value
+		case "msgctxt":
+			err = flush()
+			context = &value
+			current = context
+		case "msgid":
+			if source != nil {
+				err = flush()
+			}
+			source = &value
+			current = source
+		case "msgstr":
+			translation = &value
+			current = translation
+		default:
+			return nil, fmt.Errorf("line %d: unsupported keyword %v", lineNumber, keyword)
+		}
+	}
+	if err == nil {
+		err = scanner.Err()
+	}
+	if err == nil {
+		err = flush()
+	}
+	return
+}
@@ -0,0 +1,270 @@
+package convert
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/inkyblackness/res/chunk"
+)
+
+func TestTextCatalogRoundTrip(t *testing.T) {
+	entries := []CatalogEntry{
+		{ChunkID: 0x0867, BlockID: 0, Source: "Hello", Translation: "Hallo"},
+		{ChunkID: 0x0867, BlockID: 1, Source: "two\nlines", Translation: ""},
+		{ChunkID: 0x0867, BlockID: 2, Source: `quote " and backslash \`, Translation: "tab\there"},
+		{ChunkID: 0x0868, BlockID: 12, Source: "comma, \"csv\" quotes", Translation: "Größe\x01"},
+		{ChunkID: 0x0868, BlockID: 13, Source: "", Translation: ""},
+	}
+	for _, extension := range TextCatalogExtensions {
+		t.Run(extension, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "catalog"+extension)
+			if err := ToTextCatalog(fileName, entries); err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+			read, err := ReadTextCatalog(fileName)
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if !reflect.DeepEqual(read, entries) {
+				t.Errorf("entries = %+v, expected %+v", read, entries)
+			}
+		})
+	}
+}
+
+func TestToTextCatalogGolden(t *testing.T) {
+	entries := []CatalogEntry{{ChunkID: 0x0867, BlockID: 3, Source: "a \"b\"\nc", Translation: "d"}}
+	tests := []struct {
+		extension string
+		expected  string
+	}{
+		{".po", "msgid \"\"\nmsgstr \"Content-Type: text/plain; charset=UTF-8\\n\"\n\n" +
+			"msgctxt \"0x0867/3\"\nmsgid \"a \\\"b\\\"\\nc\"\nmsgstr \"d\"\n"},
+		{".csv", "chunk,block,source,translation\n0x0867,3,\"a \"\"b\"\"\nc\",d\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.extension, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "catalog"+tc.extension)
+			if err := ToTextCatalog(fileName, entries); err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+			data, _ := ioutil.ReadFile(fileName)
+			if string(data) != tc.expected {
+				t.Errorf("catalog = %q, expected %q", string(data), tc.expected)
+			}
+		})
+	}
+}
+
+func TestPoCatalogKeepsFuzzyFlag(t *testing.T) {
+	entries := []CatalogEntry{
+		{ChunkID: 0x0867, BlockID: 0, Source: "a", Translation: "b", Fuzzy: true},
+		{ChunkID: 0x0867, BlockID: 1, Source: "c", Translation: "d"}}
+	fileName := filepath.Join(t.TempDir(), "catalog.po")
+	if err := ToTextCatalog(fileName, entries); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	read, err := ReadTextCatalog(fileName)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !reflect.DeepEqual(read, entries) {
+		t.Errorf("entries = %+v, expected %+v", read, entries)
+	}
+}
+
+func TestReadTextCatalogGolden(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected []CatalogEntry
+	}{
+		{"po with header, comments and continuation lines", "catalog.po",
+			"# translator comment\n" +
+				"msgid \"\"\n" +
+				"msgstr \"\"\n" +
+				"\"Content-Type: text/plain; charset=UTF-8\\n\"\n" +
+				"\n" +
+				"#: cybstrng.res\n" +
+				"msgctxt \"0x0867/0\"\n" +
+				"msgid \"\"\n" +
+				"\"first \"\n" +
+				"\"line\\n\"\n" +
+				"\"second line\"\n" +
+				"msgstr \"erste \\\"Zeile\\\"\\n\"\n" +
+				"  \"zweite Zeile\"\n" +
+				"\n" +
+				"msgctxt \"0x0867/1\"\n" +
+				"msgid \"untranslated\"\n" +
+				"msgstr \"\"\n",
+			[]CatalogEntry{
+				{ChunkID: 0x0867, BlockID: 0, Source: "first line\nsecond line", Translation: "erste \"Zeile\"\nzweite Zeile"},
+				{ChunkID: 0x0867, BlockID: 1, Source: "untranslated", Translation: ""}}},
+		{"po with fuzzy entries", "catalog.po",
+			"#, fuzzy\n" +
+				"msgid \"\"\n" +
+				"msgstr \"\"\n" +
+				"\n" +
+				"#, c-format, fuzzy\n" +
+				"msgctxt \"0x0867/0\"\n" +
+				"msgid \"a\"\n" +
+				"msgstr \"b\"\n" +
+				"#, c-format\n" +
+				"msgctxt \"0x0867/1\"\n" +
+				"msgid \"fuzzy\"\n" +
+				"msgstr \"c\"\n",
+			[]CatalogEntry{
+				{ChunkID: 0x0867, BlockID: 0, Source: "a", Translation: "b", Fuzzy: true},
+				{ChunkID: 0x0867, BlockID: 1, Source: "fuzzy", Translation: "c"}}},
+		{"po with gettext escapes", "catalog.po",
+			`msgctxt "0x0867/0"` + "\n" +
+				`msgid "it\'s \a\b\f\v\r\?"` + "\n" +
+				`msgstr "\x01\x7e\101\0\1234"` + "\n",
+			[]CatalogEntry{{ChunkID: 0x0867, BlockID: 0, Source: "it's \a\b\f\v\r?", Translation: "\x01~A\x00S4"}}},
+		{"csv with header", "catalog.csv",
+			"chunk,block,source,translation\n0x0867,0,Hello,Hallo\n2151, 1 ,\"multi\nline\",\n",
+			[]CatalogEntry{
+				{ChunkID: 0x0867, BlockID: 0, Source: "Hello", Translation: "Hallo"},
+				{ChunkID: 0x0867, BlockID: 1, Source: "multi\nline", Translation: ""}}},
+		{"csv without header", "catalog.CSV",
+			"0x0001,2,a,b\n",
+			[]CatalogEntry{{ChunkID: 0x0001, BlockID: 2, Source: "a", Translation: "b"}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := ReadTextCatalog(writeTestFile(t, tc.file, tc.content))
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if !reflect.DeepEqual(entries, tc.expected) {
+				t.Errorf("entries = %+v, expected %+v", entries, tc.expected)
+			}
+		})
+	}
+}
+
+func TestReadTextCatalogRejectsMalformedInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"po context without block", "catalog.po", "msgctxt \"0x0867\"\nmsgid \"a\"\nmsgstr \"b\"\n"},
+		{"po invalid chunk", "catalog.po", "msgctxt \"chunk/1\"\nmsgid \"a\"\nmsgstr \"b\"\n"},
+		{"po chunk out of range", "catalog.po", "msgctxt \"0x10000/1\"\nmsgid \"a\"\nmsgstr \"b\"\n"},
+		{"po unquoted string", "catalog.po", "msgctxt \"0x0867/1\"\nmsgid a\nmsgstr \"b\"\n"},
+		{"po unterminated string", "catalog.po", "msgctxt \"0x0867/1\"\nmsgid \"a\nmsgstr \"b\"\n"},
+		{"po invalid escape", "catalog.po", "msgctxt \"0x0867/1\"\nmsgid \"\\q\"\nmsgstr \"b\"\n"},
+		{"po hexadecimal escape without digits", "catalog.po", `msgctxt "0x0867/1"` + "\n" + `msgid "\xg"` + "\nmsgstr \"b\"\n"},
+		{"po octal escape out of range", "catalog.po", `msgctxt "0x0867/1"` + "\n" + `msgid "\400"` + "\nmsgstr \"b\"\n"},
+		{"po unicode escape", "catalog.po", `msgctxt "0x0867/1"` + "\n" + `msgid "\u00e4"` + "\nmsgstr \"b\"\n"},
+		{"po unescaped quote", "catalog.po", `msgctxt "0x0867/1"` + "\n" + `msgid "a"b"` + "\nmsgstr \"b\"\n"},
+		{"po keyword without string", "catalog.po", "msgctxt\n"},
+		{"po string without keyword", "catalog.po", "\"orphan\"\n"},
+		{"po unsupported keyword", "catalog.po", "msgctxt \"0x0867/1\"\nmsgid \"a\"\nmsgid_plural \"as\"\nmsgstr \"b\"\n"},
+		{"csv missing column", "catalog.csv", "0x0867,1,a\n"},
+		{"csv invalid chunk", "catalog.csv", "chunk,block,source,translation\nabc,1,a,b\n"},
+		{"csv invalid block", "catalog.csv", "0x0867,-1,a,b\n"},
+		{"csv unterminated quote", "catalog.csv", "0x0867,1,\"a,b\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ReadTextCatalog(writeTestFile(t, tc.file, tc.content)); err == nil {
+				t.Errorf("malformed input was accepted")
+			}
+		})
+	}
+}
+
+func TestTextCatalog(t *testing.T) {
+	cp850, _ := LoadCodepage("cp850")
+	cp1252, _ := LoadCodepage("cp1252")
+	translation := testBlocks(EncodeTexts(cp1252, []string{"Größe", "neu", "extra"}))
+	reference := testBlocks(EncodeTexts(cp850, []string{"Size", "new"}))
+
+	tests := []struct {
+		name      string
+		reference chunk.BlockProvider
+		expected  []CatalogEntry
+	}{
+		{"without reference", nil, []CatalogEntry{
+			{ChunkID: 0x0867, BlockID: 0, Source: "Größe"},
+			{ChunkID: 0x0867, BlockID: 1, Source: "neu"},
+			{ChunkID: 0x0867, BlockID: 2, Source: "extra"}}},
+		{"with reference", reference, []CatalogEntry{
+			{ChunkID: 0x0867, BlockID: 0, Source: "Size", Translation: "Größe"},
+			{ChunkID: 0x0867, BlockID: 1, Source: "new", Translation: "neu"},
+			{ChunkID: 0x0867, BlockID: 2, Source: "", Translation: "extra"}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := TextCatalog(0x0867, translation, cp1252, tc.reference, cp850)
+			if err != nil {
+				t.Fatalf("catalog failed: %v", err)
+			}
+			if !reflect.DeepEqual(entries, tc.expected) {
+				t.Errorf("entries = %+v, expected %+v", entries, tc.expected)
+			}
+		})
+	}
+}
+
+func TestMergeTextCatalog(t *testing.T) {
+	texts := []string{"one", "two", "three"}
+	tests := []struct {
+		name      string
+		reference []string
+		entries   []CatalogEntry
+		expected  []string
+		merge     CatalogMerge
+	}{
+		{"all translated", nil,
+			[]CatalogEntry{
+				{ChunkID: 1, BlockID: 2, Source: "three", Translation: "drei"},
+				{ChunkID: 1, BlockID: 0, Source: "one", Translation: "eins"},
+				{ChunkID: 1, BlockID: 1, Source: "two", Translation: "zwei"}},
+			[]string{"eins", "zwei", "drei"},
+			CatalogMerge{Translated: 3}},
+		{"empty translations are kept", nil,
+			[]CatalogEntry{{ChunkID: 1, BlockID: 1, Source: "two", Translation: ""}},
+			[]string{"one", "two", "three"},
+			CatalogMerge{Untranslated: []int{0, 1, 2}}},
+		{"other chunks and missing blocks are stale", nil,
+			[]CatalogEntry{
+				{ChunkID: 2, BlockID: 0, Translation: "x"},
+				{ChunkID: 1, BlockID: 3, Translation: "y"},
+				{ChunkID: 1, BlockID: 0, Translation: "eins"}},
+			[]string{"eins", "two", "three"},
+			CatalogMerge{Translated: 1, Untranslated: []int{1, 2}, Stale: []string{"0x0002/0", "0x0001/3"}}},
+		{"changed sources are stale with reference", []string{"One", "Two"},
+			[]CatalogEntry{
+				{ChunkID: 1, BlockID: 0, Source: "One", Translation: "eins"},
+				{ChunkID: 1, BlockID: 1, Source: "Old two", Translation: "zwei"},
+				{ChunkID: 1, BlockID: 2, Source: "Three", Translation: "drei"}},
+			[]string{"eins", "two", "three"},
+			CatalogMerge{Translated: 1, Untranslated: []int{1, 2}, Stale: []string{"0x0001/1", "0x0001/2"}}},
+		{"fuzzy translations are not applied", nil,
+			[]CatalogEntry{
+				{ChunkID: 1, BlockID: 0, Source: "one", Translation: "eins", Fuzzy: true},
+				{ChunkID: 1, BlockID: 1, Source: "two", Translation: "zwei"}},
+			[]string{"one", "zwei", "three"},
+			CatalogMerge{Translated: 1, Untranslated: []int{0, 2}, Fuzzy: []string{"0x0001/0"}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, merge := MergeTextCatalog(1, texts, tc.reference, tc.entries)
+			if !reflect.DeepEqual(merged, tc.expected) {
+				t.Errorf("merged = %q, expected %q", merged, tc.expected)
+			}
+			if !reflect.DeepEqual(merge, tc.merge) {
+				t.Errorf("merge = %+v, expected %+v", merge, tc.merge)
+			}
+		})
+	}
+	if texts[0] != "one" {
+		t.Errorf("texts were modified")
+	}
+}
@@ -11,19 +11,25 @@ import (
 
 // ToPng extracts a bitmap from given block data and saves it to a file.
 // The given palette is used should the bitmap not have a private palette.
-func ToPng(fileName string, blockData []byte, palette color.Palette) (result bool) {
-	bitmap, _ := image.Read(bytes.NewReader(blockData))
-
-	if bitmap != nil {
-		img := image.FromBitmap(bitmap, palette)
-		file, _ := os.Create(fileName)
-
-		if file != nil {
-			defer file.Close()
-			png.Encode(file, img)
-			result = true
-		}
+// Returns ErrPaletteRequired, without creating the file, if there is no palette.
+func ToPng(fileName string, blockData []byte, palette color.Palette) (err error) {
+	bitmap, err := image.Read(bytes.NewReader(blockData))
+	if err != nil {
+		return
 	}
+	img := image.FromBitmap(bitmap, palette)
+	if len(img.Palette) == 0 {
+		return ErrPaletteRequired
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
 
-	return
+	return png.Encode(file, img)
 }
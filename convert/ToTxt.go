@@ -9,27 +9,46 @@ import (
 	"github.com/inkyblackness/res/text"
 )
 
-// ToTxt extracts all blocks from a given holder and writes
-func ToTxt(fileName string, holder chunk.BlockProvider) (result bool) {
-	file, _ := os.Create(fileName)
-
-	if file != nil {
-		defer file.Close()
-		cp := text.DefaultCodepage()
-		var decoded Text
-
-		for blockID := 0; blockID < holder.BlockCount(); blockID++ {
-			temp := blockID
-			blockReader, _ := holder.Block(blockID)
-			blockData, _ := ioutil.ReadAll(blockReader)
-			decoded.Entries = append(decoded.Entries, TextEntry{Block: &temp, CData: cp.Decode(blockData)})
+// DecodeTexts decodes all blocks of the given holder with the code page.
+func DecodeTexts(holder chunk.BlockProvider, cp text.Codepage) (texts []string, err error) {
+	for blockID := 0; blockID < holder.BlockCount(); blockID++ {
+		blockReader, blockErr := holder.Block(blockID)
+		if blockErr != nil {
+			return nil, blockErr
 		}
-		enc := xml.NewEncoder(file)
-		enc.Indent("", "    ")
-		if err := enc.Encode(&decoded); err == nil {
-			result = true
+		blockData, dataErr := ioutil.ReadAll(blockReader)
+		if dataErr != nil {
+			return nil, dataErr
 		}
+		texts = append(texts, cp.Decode(blockData))
 	}
-
 	return
 }
+
+// ToTxt extracts all blocks from a given holder and writes them as entries of one XML file.
+// The blocks are decoded with the given code page.
+func ToTxt(fileName string, holder chunk.BlockProvider, cp text.Codepage) (err error) {
+	texts, err := DecodeTexts(holder, cp)
+	if err != nil {
+		return
+	}
+	var decoded Text
+	for blockID, value := range texts {
+		temp := blockID
+		decoded.Entries = append(decoded.Entries, TextEntry{Block: &temp, CData: value})
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	enc := xml.NewEncoder(file)
+	enc.Indent("", "    ")
+
+	return enc.Encode(&decoded)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"image/color"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 
@@ -12,6 +13,23 @@ import (
 	"github.com/inkyblackness/res/geometry/command"
 )
 
+// TextureBaseID is the chunk identifier of the first texture models refer to.
+const TextureBaseID = 0x01DB
+
+// stickyWriter forwards writes until the first error, which it keeps and returns for all further writes.
+type stickyWriter struct {
+	writer io.Writer
+	err    error
+}
+
+func (sticky *stickyWriter) Write(p []byte) (n int, err error) {
+	if sticky.err != nil {
+		return 0, sticky.err
+	}
+	n, sticky.err = sticky.writer.Write(p)
+	return n, sticky.err
+}
+
 type wavefrontWriter struct {
 	objFile io.Writer
 	mtlFile io.Writer
@@ -23,6 +41,9 @@ type wavefrontWriter struct {
 
 	usedMaterials map[string]bool
 	lastMaterial  string
+	textureIDs    []uint16
+
+	err error
 }
 
 func (writer *wavefrontWriter) Nodes(anchor geometry.NodeAnchor) {
@@ -43,6 +64,10 @@ func (writer *wavefrontWriter) defineMaterial(name string) {
 }
 
 func (writer *wavefrontWriter) defineMaterialColor(color geometry.ColorIndex) {
+	if int(color) >= len(writer.palette) {
+		writer.err = ErrPaletteRequired
+		return
+	}
 	limit := float32(0xFFFF)
 	r, g, b, _ := writer.palette[int(color)].RGBA()
 	fmt.Fprintf(writer.mtlFile, "Ka %f %f %f\n", float32(r)/limit, float32(g)/limit, float32(b)/limit)
@@ -82,7 +107,8 @@ func (writer *wavefrontWriter) useTextureMaterial(textureID uint16) {
 
 	if !writer.usedMaterials[name] {
 		writer.defineMaterial(name)
-		fmt.Fprintf(writer.mtlFile, "map_Kd %04X_000.png\n", 0x01DB+textureID)
+		writer.textureIDs = append(writer.textureIDs, TextureBaseID+textureID)
+		fmt.Fprintf(writer.mtlFile, "map_Kd %04X_000.png\n", TextureBaseID+textureID)
 	}
 	writer.useMaterial(name)
 }
@@ -123,39 +149,73 @@ func (writer *wavefrontWriter) TextureMapped(face geometry.TextureMappedFace) {
 	writer.vtCounter += len(face.TextureCoordinates())
 }
 
+// ModelTextures returns the chunk identifiers of the textures the geometry model in given block data refers to.
+func ModelTextures(blockData []byte) (textureIDs []uint16, err error) {
+	model, err := command.LoadModel(bytes.NewReader(blockData))
+	if err != nil {
+		return
+	}
+	writer := &wavefrontWriter{
+		objFile:       ioutil.Discard,
+		mtlFile:       ioutil.Discard,
+		usedMaterials: make(map[string]bool)}
+	model.WalkAnchors(writer)
+	return writer.textureIDs, nil
+}
+
 // ToWavefrontObj extracts a geometry model from given block data and saves
 // the 3D model as a Wavefront OBJ file with accompanying material file.
-func ToWavefrontObj(fileName string, blockData []byte, palette color.Palette) (result bool) {
+// Returns ErrPaletteRequired, without creating any file, if the model has colored faces
+// and there is no palette.
+func ToWavefrontObj(fileName string, blockData []byte, palette color.Palette) (err error) {
 	model, err := command.LoadModel(bytes.NewReader(blockData))
-
-	if err == nil {
-		objFile, _ := os.Create(fileName + ".obj")
-		mtlFile, _ := os.Create(fileName + ".mtl")
-
-		if objFile != nil {
-			defer objFile.Close()
-		}
-		if mtlFile != nil {
-			defer mtlFile.Close()
-		}
-		if objFile != nil && mtlFile != nil {
-			fmt.Fprintf(objFile, "mtllib %s\n", path.Base(fileName+".mtl"))
-			vertexCount := model.VertexCount()
-			for i := 0; i < vertexCount; i++ {
-				position := model.Vertex(i).Position()
-				fmt.Fprintf(objFile, "v %f %f %f\n", -position.X(), -position.Y(), -position.Z())
-			}
-
-			writer := &wavefrontWriter{
-				objFile:       objFile,
-				mtlFile:       mtlFile,
-				palette:       palette,
-				usedMaterials: make(map[string]bool)}
-			model.WalkAnchors(writer)
-
-			result = true
+	if err != nil {
+		return
+	}
+	check := &wavefrontWriter{
+		objFile:       ioutil.Discard,
+		mtlFile:       ioutil.Discard,
+		palette:       palette,
+		usedMaterials: make(map[string]bool)}
+	model.WalkAnchors(check)
+	if check.err != nil {
+		return check.err
+	}
+	closeFile := func(file *os.File) {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
 		}
 	}
+	objFile, err := os.Create(fileName + ".obj")
+	if err != nil {
+		return
+	}
+	defer closeFile(objFile)
+	mtlFile, err := os.Create(fileName + ".mtl")
+	if err != nil {
+		return
+	}
+	defer closeFile(mtlFile)
+
+	objOut := &stickyWriter{writer: objFile}
+	mtlOut := &stickyWriter{writer: mtlFile}
+	fmt.Fprintf(objOut, "mtllib %s\n", path.Base(fileName+".mtl"))
+	vertexCount := model.VertexCount()
+	for i := 0; i < vertexCount; i++ {
+		position := model.Vertex(i).Position()
+		fmt.Fprintf(objOut, "v %f %f %f\n", -position.X(), -position.Y(), -position.Z())
+	}
 
+	writer := &wavefrontWriter{
+		objFile:       objOut,
+		mtlFile:       mtlOut,
+		palette:       palette,
+		usedMaterials: make(map[string]bool)}
+	model.WalkAnchors(writer)
+
+	err = objOut.err
+	if err == nil {
+		err = mtlOut.err
+	}
 	return
 }
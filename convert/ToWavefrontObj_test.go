@@ -0,0 +1,38 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// failingWriter accepts a number of writes and fails afterwards.
+type failingWriter struct {
+	accepted int
+	writes   int
+}
+
+func (writer *failingWriter) Write(p []byte) (int, error) {
+	writer.writes++
+	if writer.writes > writer.accepted {
+		return 0, errors.New("disk full")
+	}
+	return len(p), nil
+}
+
+func TestStickyWriterKeepsFirstError(t *testing.T) {
+	target := &failingWriter{accepted: 1}
+	sticky := &stickyWriter{writer: target}
+	fmt.Fprintf(sticky, "v %f\n", 1.0)
+	if sticky.err != nil {
+		t.Fatalf("first write failed: %v", sticky.err)
+	}
+	fmt.Fprintf(sticky, "v %f\n", 2.0)
+	fmt.Fprintf(sticky, "v %f\n", 3.0)
+	if sticky.err == nil {
+		t.Errorf("write error was not kept")
+	}
+	if target.writes != 2 {
+		t.Errorf("target received %d writes, expected none after the error", target.writes)
+	}
+}
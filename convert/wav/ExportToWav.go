@@ -8,8 +8,17 @@ import (
 )
 
 // ExportToWav writes a file in the RIFF WAVE format, based on the provided sound data.
-func ExportToWav(fileName string, soundData audio.SoundData) {
-	file, _ := os.Create(fileName)
-	defer file.Close()
-	wav.Save(file, soundData.SampleRate(), soundData.Samples(0, soundData.SampleCount()))
+func ExportToWav(fileName string, soundData audio.SoundData) (err error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	err = wav.Save(file, soundData.SampleRate(), soundData.Samples(0, soundData.SampleCount()))
+
+	return
 }
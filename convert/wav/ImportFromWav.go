@@ -9,11 +9,12 @@ import (
 
 // ImportFromWav reads the file identified by given name and returns a SoundData instance
 // that wraps the contained samples.
-func ImportFromWav(fileName string) audio.SoundData {
-	file, _ := os.Open(fileName)
+func ImportFromWav(fileName string) (data audio.SoundData, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
 	defer file.Close()
 
-	data, _ := wav.Load(file)
-
-	return data
+	return wav.Load(file)
 }
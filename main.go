@@ -9,7 +9,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/docopt/docopt-go"
 
@@ -21,6 +23,7 @@ import (
 	"github.com/inkyblackness/res/image"
 	"github.com/inkyblackness/res/movi"
 	"github.com/inkyblackness/res/serial"
+	"github.com/inkyblackness/res/text"
 
 	"github.com/inkyblackness/chunkie/convert"
 	"github.com/inkyblackness/chunkie/convert/wav"
@@ -39,23 +42,77 @@ func usage() string {
 	return Title + `
 
 Usage:
-  chunkie export <resource-file> <chunk-id> [--block=<block-id>] [--raw] [--pal=<palette-file>] [--pal-id=<palette-id>] [--fps=<framerate>] [<folder>]
-  chunkie import <resource-file> <chunk-id> [--block=<block-id>] [--compressed] [--force-transparency] <source-file>
+  chunkie list <resource-file> [--type=<types>] [--json]
+  chunkie export <resource-file> <chunk-id> [--block=<block-id>] [--raw] [--pal=<palette-file>] [--pal-id=<palette-id>] [--game-dir=<dir>] [--fps=<framerate>] [--text-format=<format>] [--reference=<resource-file>] [--codepage=<codepage>] [--json] [<folder>]
+  chunkie import <resource-file> <chunk-id> [--block=<block-id>] [--compressed | --uncompressed] [--force-transparency | --no-transparency] [--pal=<palette-file>] [--pal-id=<palette-id>] [--game-dir=<dir>] [--dither] [--strict-palette] [--private-palette] [--anchor=<box>] [--reference=<resource-file>] [--codepage=<codepage>] [--text-limits=<file>] [--dry-run] [--no-backup] [--output=<file>] [--json] <source-file>
+  chunkie import-folder <resource-file> [--compressed | --uncompressed] [--force-transparency | --no-transparency] [--pal=<palette-file>] [--pal-id=<palette-id>] [--game-dir=<dir>] [--dither] [--strict-palette] [--private-palette] [--reference=<resource-file>] [--codepage=<codepage>] [--text-limits=<file>] [--dry-run] [--no-backup] [--output=<file>] [--json] <folder>
+  chunkie create <resource-file> [--json]
+  chunkie add <resource-file> <chunk-id> --data-type=<type> [--chunk-compressed] [--fragmented] [--compressed | --uncompressed] [--force-transparency | --no-transparency] [--pal=<palette-file>] [--pal-id=<palette-id>] [--game-dir=<dir>] [--dither] [--strict-palette] [--private-palette] [--anchor=<box>] [--codepage=<codepage>] [--text-limits=<file>] [--dry-run] [--no-backup] [--output=<file>] [--json] <source-files>...
+  chunkie delete <resource-file> <chunk-id> [--block=<block-id> [--truncate]] [--force] [--dry-run] [--no-backup] [--output=<file>] [--json]
+  chunkie copy <src-resource-file> <src-chunk-id> <dst-resource-file> [<dst-chunk-id>] [--move] [--overwrite] [--dry-run] [--no-backup] [--json]
+  chunkie diff <resource-file-a> <resource-file-b> [--deep] [--json]
+  chunkie patch create <original-file> <modified-file> <patch-file> [--json]
+  chunkie patch apply <original-file> <patch-file> <output-file> [--json]
+  chunkie game [--game-dir=<dir>] [--json]
+  chunkie grep <pattern> <resource-files>... [--ignore-case] [--codepage=<codepage>] [--json]
   chunkie -h | --help
   chunkie --version
 
 Options:
   <resource-file>        The resource file to work on.
   <chunk-id>             The chunk identifier. Defaults to decimal, use "0x" as prefix for hexadecimal. "all" for all.
-  --block=<block-id>     The block identifier. Defaults to decimal, use "0x" as prefix for hexadecimal. "all" for all. [default: 0]
+  --block=<block-id>     The block identifier. Defaults to decimal, use "0x" as prefix for hexadecimal. "all" for all. Defaults to 0 for export and import.
+  --type=<types>         Comma separated list of content types to list, e.g. "bitmap,sound". Lists all if not provided.
   --raw                  With this flag, the chunk will be exported without conversion to a common file format.
   --compressed           With this flag, imported bitmaps will be compressed.
-  --force-transparency   With this flag, imported bitmaps will be marked to have transparency. [default: false]
-  --pal=<palette-file>   For handling bitmaps & models, use this palette file to write color information
-  --pal-id=<palette-id>  Optional palette chunk identifier. If not provided, uses first palette found in palette-file.
+  --uncompressed         With this flag, imported bitmaps will not be compressed.
+  --data-type=<type>     The content type of the chunk to add, either by name (e.g. "bitmap") or numerical value.
+  --chunk-compressed     With this flag, the added chunk will be stored compressed.
+  --fragmented           With this flag, the added chunk will be fragmented, i.e. can hold more than one block.
+  --force-transparency   With this flag, imported bitmaps will be marked to have transparency.
+  --no-transparency      With this flag, imported bitmaps will not be marked to have transparency.
+  --pal=<palette-file>   For handling bitmaps & models, use this palette file to write color information.
+                         Either a resource file, or a .pal (JASC-PAL or raw VGA), .gpl, .act or paletted .png file.
+  --pal-id=<palette-id>  Optional palette chunk identifier. If not provided, uses first palette found in a resource palette-file.
+                         Without --pal, the palette of the game directory given with --game-dir is used.
+                         When exporting, gamepal.res next to the resource file is used otherwise, if present.
+  --game-dir=<dir>       The directory of the installed game. Can also be set as "gameDir" in the configuration file
+                         chunkie/config.json in the user configuration directory.
+  --dither               With this flag, true-color images are dithered when mapped to the palette on import.
+  --strict-palette       With this flag, imported paletted images must only use colors of the palette given with --pal.
+  --private-palette      With this flag, imported bitmaps are stored with their own palette.
+  --anchor=<box>         The anchor (hotspot) box of imported bitmaps as "left,top,right,bottom".
   --fps=<framerate>      The frames per second to emulate when exporting movies. 0 names files after timestamp. [default: 0]
+  --text-format=<format>  The format to export texts in: "xml", or the translation catalogs "po" (gettext) and "csv". [default: xml]
+  --reference=<resource-file>  The resource file with the texts in the source language, for translation catalogs.
+  --codepage=<codepage>  The code page of texts and subtitles: "default" for the one of the game, "cp437", "cp850", "cp1252"
+                         or a mapping file with one byte value and character per line (e.g. "0x80 U+00C7"). [default: default]
+  --text-limits=<file>   A JSON file with the maximum length, lines and line length of imported texts per chunk.
+  --truncate             With this flag, the block and all following blocks will be deleted.
+  --force                With this flag, blocks will also be deleted from chunks that are not fragmented.
+  --move                 With this flag, the copied chunk will be deleted from the source file.
+  --overwrite            With this flag, an existing chunk in the destination file will be replaced.
+  --deep                 With this flag, changed bitmaps, palettes and texts are decoded to describe the change.
+  --dry-run              With this flag, the changes are only reported and the resource file is not modified.
+  --no-backup            With this flag, no backup (.bak) of a modified resource file will be kept.
+  --output=<file>        Write the modified resource file to this file, leaving the original untouched.
+  --json                 Write one JSON record per chunk or block to standard output instead of text.
+  <resource-file-a>      The original resource file to compare.
+  <resource-file-b>      The modified resource file to compare.
+  <original-file>        The unmodified resource file a patch is based on.
+  <modified-file>        The modified resource file to create a patch for.
+  <patch-file>           The patch file to write or apply.
+  <output-file>          The resource file to write the patched result to.
+  <src-resource-file>    The resource file to copy from.
+  <src-chunk-id>         The identifier of the chunk to copy.
+  <dst-resource-file>    The resource file to copy into. Can be the same as the source file to re-number a chunk.
+  <dst-chunk-id>         The identifier of the copied chunk. Defaults to the source chunk identifier.
   <folder>               The path of the folder to use. [default: .]
   <source-file>          The source file to import.
+  <source-files>         The source files to import, one per block.
+  <pattern>              The regular expression to search texts and subtitles for.
+  <resource-files>       The resource files to search.
+  --ignore-case          With this flag, the pattern matches regardless of case.
   -h --help              Show this screen.
   --version              Show version.
 `
@@ -63,19 +120,51 @@ Options:
 
 func main() {
 	arguments, _ := docopt.Parse(usage(), nil, true, Title, false)
-	fmt.Printf("%v\n", arguments)
+	rep := newReporter(os.Stdout, arguments["--json"].(bool))
 
-	if arguments["export"].(bool) {
+	rep.verbose = arguments["--dry-run"].(bool)
+	process(arguments, rep)
+	if rep.failed {
+		os.Exit(1)
+	}
+}
+
+func process(arguments map[string]interface{}, rep *reporter) {
+	if arguments["list"].(bool) {
+		resourceFile := arguments["<resource-file>"].(string)
+		inFile, inFileErr := os.Open(resourceFile)
+		if inFileErr != nil {
+			rep.failure("Failed to open file: %v", inFileErr)
+			return
+		}
+		defer inFile.Close()
+		provider, providerErr := resfile.ReaderFrom(inFile)
+		if providerErr != nil {
+			rep.failure("Failed to read resource file: %v", providerErr)
+			return
+		}
+		typeFilter := ""
+		if typeArgument := arguments["--type"]; typeArgument != nil {
+			typeFilter = typeArgument.(string)
+		}
+		filter, filterErr := parseContentTypeFilter(typeFilter)
+		if filterErr != nil {
+			rep.failure("Invalid type filter: %v", filterErr)
+			return
+		}
+
+		listChunks(rep, provider, filter)
+	} else if arguments["export"].(bool) {
 		resourceFile := arguments["<resource-file>"].(string)
 		inFile, inFileErr := os.Open(resourceFile)
 		if inFileErr != nil {
-			fmt.Printf("Failed to open file\n")
+			rep.failure("Failed to open file: %v", inFileErr)
 			return
 		}
 		defer inFile.Close()
 		provider, providerErr := resfile.ReaderFrom(inFile)
 		if providerErr != nil {
-			fmt.Printf("Failed to read resource file: %v\n", providerErr)
+			rep.failure("Failed to read resource file: %v", providerErr)
 			return
 		}
 		chunkText := arguments["<chunk-id>"].(string)
@@ -83,48 +172,109 @@ func main() {
 		if chunkText != "all" {
 			chunkSelection, _ = strconv.ParseInt(chunkText, 0, 16)
 		}
-		blockText := arguments["--block"].(string)
+		blockText := blockArgument(arguments)
 		blockSelection := int64(-1)
 		if blockText != "all" {
 			blockSelection, _ = strconv.ParseInt(blockText, 0, 16)
 		}
 		framesPerSecond, _ := strconv.ParseFloat(arguments["--fps"].(string), 32)
 		raw := arguments["--raw"].(bool)
-		palArgument := arguments["--pal"]
+		textFormat := arguments["--text-format"].(string)
+		if !textFormats[textFormat] {
+			rep.failure("Invalid text format <%v>", textFormat)
+			return
+		}
 		palIDArgument := arguments["--pal-id"]
-		var palette color.Palette
-		paletteID := uint64(0)
 		folderArgument := arguments["<folder>"]
 		folder := "."
 
-		if palIDArgument != nil {
-			paletteID, _ = strconv.ParseUint(palIDArgument.(string), 0, 16)
+		game, gameErr := explicitGameArguments(arguments)
+		if gameErr != nil {
+			rep.failure("Failed to find game: %v", gameErr)
+			return
+		}
+		palette, paletteFile, palErr := paletteArguments(arguments, game)
+		if palErr != nil {
+			rep.failure("Failed to load palette: %v", palErr)
+			return
+		}
+		if (len(paletteFile) == 0) && (palette == nil) {
+			if gamePalette := findGamePalette(resourceFile); len(gamePalette) > 0 {
+				palette, palErr = loadPalette(gamePalette, chunk.ID(0))
+				if palErr != nil {
+					rep.failure("Failed to load palette from %v: %v", gamePalette, palErr)
+				}
+				paletteFile = gamePalette
+			}
+		}
+		if game == nil {
+			// The game of the configuration file only provides textures and frames, not the palette.
+			game, gameErr = configuredGame()
+			if gameErr != nil {
+				rep.warning("Ignoring the game directory of the configuration file: %v", gameErr)
+			}
+		}
+		codepage, codepageErr := convert.LoadCodepage(arguments["--codepage"].(string))
+		if codepageErr != nil {
+			rep.failure("Failed to load code page: %v", codepageErr)
+			return
 		}
-		if palArgument != nil {
-			palette = loadPalette(palArgument.(string), chunk.ID(uint16(paletteID)))
+		options := exportOptions{
+			raw:             raw,
+			codepage:        codepage,
+			palette:         palette,
+			framesPerSecond: float32(framesPerSecond),
+			game:            game,
+			textFormat:      textFormat}
+		if referenceArgument := arguments["--reference"]; referenceArgument != nil {
+			reference, referenceErr := loadResourceFile(referenceArgument.(string))
+			if referenceErr != nil {
+				rep.failure("Failed to read reference file: %v", referenceErr)
+				return
+			}
+			options.reference = reference
 		}
 		if folderArgument != nil {
 			folder = folderArgument.(string)
 		}
 		os.MkdirAll(folder, os.FileMode(0755))
+		manifest, manifestErr := readExportManifest(folder)
+		if manifestErr != nil {
+			if !os.IsNotExist(manifestErr) {
+				rep.failure("Failed to read existing manifest, replacing it: %v", manifestErr)
+			}
+			manifest = &exportManifest{}
+		}
 
-		processBlock := func(chunkID chunk.Identifier, selectedChunk *chunk.Chunk, blockID int) {
+		processBlock := func(chunkID chunk.Identifier, selectedChunk *chunk.Chunk, blockID int, manifestEntry *manifestChunk) {
 			outFileName := fmt.Sprintf("%04X_%03d", chunkID, blockID)
-			exportFile(provider, selectedChunk, blockID, path.Join(folder, outFileName), raw, palette, float32(framesPerSecond))
+			record := newBlockRecord(chunkID, blockID)
+			record.ContentType = contentTypeName(selectedChunk.ContentType)
+			exportFile(&record, provider, chunkID, selectedChunk, blockID, path.Join(folder, outFileName), options)
+			manifestEntry.addBlock(record, blockSize(selectedChunk, blockID))
+			rep.block(record)
 		}
 		processChunk := func(chunkID chunk.Identifier) {
 			selectedChunk, chunkErr := provider.Chunk(chunkID)
 
 			if chunkErr != nil {
-				fmt.Printf("Failed to read chunk %v: %v\n", chunkID, chunkErr)
+				rep.failure("Failed to read chunk %v: %v", chunkID, chunkErr)
 				return
 			}
+			manifestEntry := manifest.chunk(chunkID, selectedChunk)
+			manifestEntry.Raw = raw
+			manifestEntry.FramesPerSecond = framesPerSecond
+			manifestEntry.Palette = paletteFile
+			manifestEntry.PaletteID = ""
+			if palIDArgument != nil {
+				manifestEntry.PaletteID = palIDArgument.(string)
+			}
 			if blockSelection == -1 {
 				for blockID := 0; blockID < selectedChunk.BlockCount(); blockID++ {
-					processBlock(chunkID, selectedChunk, blockID)
+					processBlock(chunkID, selectedChunk, blockID, manifestEntry)
 				}
 			} else {
-				processBlock(chunkID, selectedChunk, int(blockSelection))
+				processBlock(chunkID, selectedChunk, int(blockSelection), manifestEntry)
 			}
 		}
 		if chunkSelection == -1 {
@@ -134,68 +284,342 @@ func main() {
 		} else {
 			processChunk(chunk.ID(uint16(chunkSelection)))
 		}
+		if writeErr := writeExportManifest(folder, manifest); writeErr != nil {
+			rep.failure("Failed to write manifest: %v", writeErr)
+		}
 
 	} else if arguments["import"].(bool) {
 		resourceFile := arguments["<resource-file>"].(string)
 		chunkID, _ := strconv.ParseUint(arguments["<chunk-id>"].(string), 0, 16)
-		blockID, _ := strconv.ParseUint(arguments["--block"].(string), 0, 16)
+		blockID, _ := strconv.ParseUint(blockArgument(arguments), 0, 16)
 		sourceFile := arguments["<source-file>"].(string)
-		compressed := arguments["--compressed"].(bool)
-		forceTransparency := arguments["--force-transparency"].(bool)
+		if (arguments["--block"] != nil) && isTextFile(sourceFile) {
+			rep.failure("%v holds all blocks of a text chunk, --block can not be used with it", sourceFile)
+			return
+		}
+		options, optionsErr := importArguments(arguments)
+		if optionsErr != nil {
+			rep.failure("%v", optionsErr)
+			return
+		}
+
+		record := newBlockRecord(chunk.ID(uint16(chunkID)), int(blockID))
+		importData(&record, resourceFile, chunk.ID(uint16(chunkID)), int(blockID), sourceFile, options, saveArguments(arguments))
+		rep.block(record)
+	} else if arguments["import-folder"].(bool) {
+		resourceFile := arguments["<resource-file>"].(string)
+		folder := arguments["<folder>"].(string)
+		options, optionsErr := importArguments(arguments)
+		if optionsErr != nil {
+			rep.failure("%v", optionsErr)
+			return
+		}
+
+		importFolder(rep, resourceFile, folder, options, saveArguments(arguments))
+	} else if arguments["patch"].(bool) {
+		originalFile := arguments["<original-file>"].(string)
+		patchFile := arguments["<patch-file>"].(string)
+
+		if arguments["create"].(bool) {
+			createPatch(rep, originalFile, arguments["<modified-file>"].(string), patchFile)
+		} else {
+			applyPatch(rep, originalFile, patchFile, arguments["<output-file>"].(string))
+		}
+	} else if arguments["create"].(bool) {
+		createResourceFile(rep, arguments["<resource-file>"].(string))
+	} else if arguments["add"].(bool) {
+		resourceFile := arguments["<resource-file>"].(string)
+		chunkID, chunkErr := strconv.ParseUint(arguments["<chunk-id>"].(string), 0, 16)
+		if chunkErr != nil {
+			rep.failure("Invalid chunk identifier: %v", chunkErr)
+			return
+		}
+		contentType, typeErr := parseContentType(arguments["--data-type"].(string))
+		if typeErr != nil {
+			rep.failure("Invalid data type: %v", typeErr)
+			return
+		}
+		properties := newChunkProperties{
+			contentType: contentType,
+			compressed:  arguments["--chunk-compressed"].(bool),
+			fragmented:  arguments["--fragmented"].(bool)}
+		sourceFiles := arguments["<source-files>"].([]string)
+		options, optionsErr := importArguments(arguments)
+		if optionsErr != nil {
+			rep.failure("%v", optionsErr)
+			return
+		}
+
+		addChunk(rep, resourceFile, chunk.ID(uint16(chunkID)), properties, sourceFiles, options, saveArguments(arguments))
+	} else if arguments["delete"].(bool) {
+		resourceFile := arguments["<resource-file>"].(string)
+		chunkID, chunkErr := strconv.ParseUint(arguments["<chunk-id>"].(string), 0, 16)
+		if chunkErr != nil {
+			rep.failure("Invalid chunk identifier: %v", chunkErr)
+			return
+		}
+		var deletion *blockDeletion
+		if blockText := arguments["--block"]; blockText != nil {
+			blockID, blockErr := strconv.ParseUint(blockText.(string), 0, 16)
+			if blockErr != nil {
+				rep.failure("Invalid block identifier: %v", blockErr)
+				return
+			}
+			deletion = &blockDeletion{
+				blockID:  int(blockID),
+				truncate: arguments["--truncate"].(bool),
+				force:    arguments["--force"].(bool)}
+		}
+
+		deleteChunk(rep, resourceFile, chunk.ID(uint16(chunkID)), deletion, saveArguments(arguments))
+	} else if arguments["grep"].(bool) {
+		codepage, codepageErr := convert.LoadCodepage(arguments["--codepage"].(string))
+		if codepageErr != nil {
+			rep.failure("Failed to load code page: %v", codepageErr)
+			return
+		}
+		grepResources(rep, arguments["<pattern>"].(string), arguments["<resource-files>"].([]string),
+			arguments["--ignore-case"].(bool), codepage)
+	} else if arguments["game"].(bool) {
+		game, gameErr := gameArguments(arguments)
+		if gameErr != nil {
+			rep.failure("Failed to find game: %v", gameErr)
+			return
+		}
+		if game == nil {
+			rep.failure("No game directory given, use --game-dir or set \"gameDir\" in %v", gameConfigFileName())
+			return
+		}
+		describeGame(rep, game)
+	} else if arguments["diff"].(bool) {
+		diffResources(rep, arguments["<resource-file-a>"].(string), arguments["<resource-file-b>"].(string), arguments["--deep"].(bool))
+	} else if arguments["copy"].(bool) {
+		sourceFile := arguments["<src-resource-file>"].(string)
+		destinationFile := arguments["<dst-resource-file>"].(string)
+		sourceID, sourceErr := strconv.ParseUint(arguments["<src-chunk-id>"].(string), 0, 16)
+		if sourceErr != nil {
+			rep.failure("Invalid source chunk identifier: %v", sourceErr)
+			return
+		}
+		destinationID := sourceID
+		if destinationText := arguments["<dst-chunk-id>"]; destinationText != nil {
+			var destinationErr error
+			destinationID, destinationErr = strconv.ParseUint(destinationText.(string), 0, 16)
+			if destinationErr != nil {
+				rep.failure("Invalid destination chunk identifier: %v", destinationErr)
+				return
+			}
+		}
+		transfer := chunkTransfer{
+			move:      arguments["--move"].(bool),
+			overwrite: arguments["--overwrite"].(bool)}
+
+		copyChunk(rep, sourceFile, chunk.ID(uint16(sourceID)), destinationFile, chunk.ID(uint16(destinationID)), transfer, saveArguments(arguments))
+	}
+}
+
+// blockArgument returns the block selection of the arguments, defaulting to the first block.
+func blockArgument(arguments map[string]interface{}) string {
+	if blockText := arguments["--block"]; blockText != nil {
+		return blockText.(string)
+	}
+	return "0"
+}
 
-		importData(resourceFile, chunk.ID(uint16(chunkID)), int(blockID), sourceFile, compressed, forceTransparency)
+// saveArguments returns the options for writing modified resource files.
+func saveArguments(arguments map[string]interface{}) saveOptions {
+	options := saveOptions{
+		backup: !arguments["--no-backup"].(bool),
+		dryRun: arguments["--dry-run"].(bool)}
+	if outputText := arguments["--output"]; outputText != nil {
+		options.outputFile = outputText.(string)
 	}
+	return options
 }
 
-func exportFile(provider chunk.Provider, selectedChunk *chunk.Chunk, blockID int,
-	outFileName string, raw bool, palette color.Palette, framesPerSecond float32) {
+// exportFile writes the given block into one or more files, based on the content type.
+// The produced files, the used converter and any problem are stored in the record.
+func exportFile(record *blockRecord, provider chunk.Provider, chunkID chunk.Identifier, selectedChunk *chunk.Chunk, blockID int,
+	outFileName string, options exportOptions) {
 	blockReader, blockErr := selectedChunk.Block(blockID)
 	contentType := selectedChunk.ContentType
-	exportRaw := raw
+	exportRaw := options.raw
+	palette := options.palette
 
 	if blockErr != nil {
-		fmt.Printf("Failed to access block %d: %v\n", blockID, blockErr)
+		record.fail("Failed to access block %d: %v", blockID, blockErr)
 		return
 	}
 	blockData, dataErr := ioutil.ReadAll(blockReader)
 	if dataErr != nil {
-		fmt.Printf("Failed to read block %d: %v\n", blockID, dataErr)
+		record.fail("Failed to read block %d: %v", blockID, dataErr)
 		return
 	}
 	if !exportRaw {
+		var convertErr error
 		if contentType == chunk.Sound {
-			soundData, _ := audio.DecodeSoundChunk(blockData)
-			wav.ExportToWav(outFileName+".wav", soundData)
+			record.Converter = "wav"
+			record.Files = []string{outFileName + ".wav"}
+			soundData, soundErr := audio.DecodeSoundChunk(blockData)
+			convertErr = soundErr
+			if soundErr == nil {
+				convertErr = wav.ExportToWav(outFileName+".wav", soundData)
+			}
 		} else if contentType == chunk.Media {
-			exportRaw = exportMedia(blockData, outFileName, framesPerSecond)
+			record.Converter = "media"
+			record.Files, convertErr = exportMedia(blockData, outFileName, options.framesPerSecond, options.codepage)
 		} else if contentType == chunk.Bitmap {
-			exportRaw = !convert.ToPng(outFileName+".png", blockData, palette)
+			record.Converter = "png"
+			record.Files = []string{outFileName + ".png", outFileName + ".png.json"}
+			convertErr = convert.ToPng(outFileName+".png", blockData, palette)
+			if convertErr == nil {
+				convertErr = convert.WriteBitmapMetadata(outFileName+".png.json", blockData)
+			}
 		} else if contentType == chunk.Geometry {
-			exportRaw = !convert.ToWavefrontObj(outFileName, blockData, palette)
+			record.Converter = "wavefront"
+			record.Files = []string{outFileName + ".obj", outFileName + ".mtl"}
+			convertErr = convert.ToWavefrontObj(outFileName, blockData, palette)
+			if (convertErr == nil) && (options.game != nil) {
+				exportModelTextures(record, options.game, blockData, path.Dir(outFileName), palette)
+			}
 		} else if contentType == chunk.VideoClip {
-			exportRaw = exportVideoClip(provider, blockData, outFileName, framesPerSecond, palette)
+			record.Converter = "videoclip"
+			record.Files, convertErr = exportVideoClip(provider, options.game, blockData, outFileName, options.framesPerSecond, palette)
+		} else if contentType == chunk.Palette {
+			record.Converter = "palette"
+			record.Files, convertErr = exportPalette(blockData, outFileName)
 		} else if contentType == chunk.Text {
-			// Don't recreate whole XML for each block since convert.ToTxt merge them into one file
+			record.Converter = options.textFormat
+			// Don't recreate whole file for each block since all blocks are merged into one file
 			if blockID == 0 {
-				exportRaw = !convert.ToTxt(outFileName+".xml", selectedChunk)
+				record.Files = []string{outFileName + "." + options.textFormat}
+				if options.textFormat == "xml" {
+					convertErr = convert.ToTxt(outFileName+".xml", selectedChunk, options.codepage)
+				} else {
+					convertErr = exportTextCatalog(record, chunkID, selectedChunk, record.Files[0], options.reference, options.codepage)
+				}
 			}
 		} else {
 			exportRaw = true
 		}
+		if convertErr == convert.ErrPaletteRequired {
+			record.fail("Skipped block %d of chunk %v (%v): palette required, specify one with --pal",
+				blockID, record.ChunkID, record.Converter)
+			record.Files = nil
+			return
+		} else if convertErr != nil {
+			record.fail("Failed to convert block %d of chunk %v (%v), exported raw instead: %v",
+				blockID, record.ChunkID, record.Converter, convertErr)
+			exportRaw = true
+		}
 	}
 	if exportRaw {
-		ioutil.WriteFile(outFileName+".bin", blockData, os.FileMode(0644))
+		record.Converter = "raw"
+		record.Files = []string{outFileName + ".bin"}
+		err := ioutil.WriteFile(outFileName+".bin", blockData, os.FileMode(0644))
+		if err != nil {
+			record.fail("Failed to write block %d of chunk %v: %v", blockID, record.ChunkID, err)
+		}
+	}
+}
+
+// textFormats lists the formats texts can be exported in.
+var textFormats = map[string]bool{
+	"xml": true,
+	"po":  true,
+	"csv": true}
+
+// exportOptions control how blocks are converted on export.
+type exportOptions struct {
+	raw bool
+
+	// palette is used for bitmaps, models and video clips. May be nil.
+	palette         color.Palette
+	framesPerSecond float32
+	// game, if not nil, provides the textures of models and the frames of video clips not found in the resource file.
+	game *gameInstallation
+	// codepage is used to decode texts and subtitles.
+	codepage text.Codepage
+	// textFormat is one of textFormats.
+	textFormat string
+	// reference, if not nil, provides the source texts of translation catalogs.
+	reference chunk.Provider
+}
+
+// exportTextCatalog writes all blocks of a text chunk as translation catalog.
+// The texts of the reference are decoded with the default code page of the game, independent of --codepage.
+func exportTextCatalog(record *blockRecord, chunkID chunk.Identifier, holder chunk.BlockProvider, fileName string,
+	reference chunk.Provider, cp text.Codepage) error {
+	referenceChunk, err := referenceText(reference, chunkID)
+	if err != nil {
+		record.warn("%v, the texts are exported as source", err)
+	}
+	var referenceBlocks chunk.BlockProvider
+	if referenceChunk != nil {
+		referenceBlocks = referenceChunk
+	}
+	entries, err := convert.TextCatalog(chunkID.Value(), holder, cp, referenceBlocks, text.DefaultCodepage())
+	if err != nil {
+		return err
+	}
+	return convert.ToTextCatalog(fileName, entries)
+}
+
+// referenceText returns the text chunk with given identifier from the reference. Returns nil if there is no reference.
+func referenceText(reference chunk.Provider, chunkID chunk.Identifier) (*chunk.Chunk, error) {
+	if reference == nil {
+		return nil, nil
+	}
+	if !hasChunk(reference, chunkID) {
+		return nil, fmt.Errorf("reference has no chunk %v", chunkID)
+	}
+	referenceChunk, err := reference.Chunk(chunkID)
+	if err != nil {
+		return nil, err
 	}
+	if referenceChunk.ContentType != chunk.Text {
+		return nil, fmt.Errorf("chunk %v of reference is not a text", chunkID)
+	}
+	return referenceChunk, nil
+}
+
+// gamePaletteFileName is the name of the resource file containing the palette of the game.
+const gamePaletteFileName = "gamepal.res"
+
+// findGamePalette looks for the palette resource file of the game in the directory of the given resource file.
+// Returns an empty string if there is none.
+func findGamePalette(resourceFile string) string {
+	dir := filepath.Dir(resourceFile)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(entry.Name(), gamePaletteFileName) {
+			return filepath.Join(dir, entry.Name())
+		}
+	}
+	return ""
 }
 
-func loadPalette(fileName string, paletteID chunk.Identifier) (pal color.Palette) {
+// loadPalette reads a palette from the given file. Palette files (see convert.LoadPaletteFile) are
+// read directly. Any other file is considered a resource file, from which the palette chunk with the given
+// identifier is used, or the first palette chunk found.
+func loadPalette(fileName string, paletteID chunk.Identifier) (pal color.Palette, err error) {
+	if convert.IsPaletteFile(fileName) {
+		return convert.LoadPaletteFile(fileName)
+	}
 	if len(fileName) > 0 {
-		inFile, _ := os.Open(fileName)
+		inFile, openErr := os.Open(fileName)
+		if openErr != nil {
+			err = openErr
+			return
+		}
 		defer inFile.Close()
 		reader, readerErr := resfile.ReaderFrom(inFile)
 
 		if readerErr != nil {
-			fmt.Printf("Failed to load palette: %v\n", readerErr)
+			err = readerErr
 			return
 		}
 		tryLoad := func(id chunk.Identifier) {
@@ -224,11 +648,33 @@ func loadPalette(fileName string, paletteID chunk.Identifier) (pal color.Palette
 	return
 }
 
-func exportMedia(blockData []byte, fileBaseName string, framesPerSecond float32) (failed bool) {
+func exportPalette(blockData []byte, fileBaseName string) (files []string, err error) {
+	palette, err := image.LoadPalette(bytes.NewReader(blockData))
+	if err != nil {
+		return
+	}
+	return convert.ToPaletteFiles(fileBaseName, palette)
+}
+
+func importPalette(record *blockRecord, sourceFile string) []byte {
+	palette, err := convert.LoadPaletteFile(sourceFile)
+	if err != nil {
+		record.fail("Failed to read palette from %v: %v", sourceFile, err)
+		return nil
+	}
+	data, err := convert.EncodePalette(palette)
+	if err != nil {
+		record.fail("Failed to encode palette from %v: %v", sourceFile, err)
+		return nil
+	}
+	return data
+}
+
+func exportMedia(blockData []byte, fileBaseName string, framesPerSecond float32, cp text.Codepage) (files []string, err error) {
 	container, err := movi.Read(bytes.NewReader(blockData))
 
 	if err == nil {
-		handler := newExportingMediaHandler(fileBaseName, container.MediaDuration(), framesPerSecond, float32(container.AudioSampleRate()))
+		handler := newExportingMediaHandler(fileBaseName, container.MediaDuration(), framesPerSecond, float32(container.AudioSampleRate()), cp)
 		dispatcher := movi.NewMediaDispatcher(container, handler)
 		more := true
 
@@ -236,20 +682,54 @@ func exportMedia(blockData []byte, fileBaseName string, framesPerSecond float32)
 			more, err = dispatcher.DispatchNext()
 		}
 		if !more {
-			handler.finish()
+			err = handler.finish()
 		}
+		files = handler.files
 	}
 
+	return
+}
+
+// exportModelTextures exports the textures referenced by a model from the texture archive of the game
+// into the given folder, named as referenced by the material file. Existing files are kept.
+func exportModelTextures(record *blockRecord, game *gameInstallation, blockData []byte, folder string, palette color.Palette) {
+	textureIDs, err := convert.ModelTextures(blockData)
 	if err != nil {
-		failed = true
+		record.warn("Failed to determine textures: %v", err)
+		return
+	}
+	if len(textureIDs) == 0 {
+		return
+	}
+	textures, err := game.store(gameTextureFileName)
+	if err != nil {
+		record.warn("Failed to load textures: %v", err)
+		return
+	}
+	for _, textureID := range textureIDs {
+		textureFileName := path.Join(folder, fmt.Sprintf("%04X_000.png", textureID))
+		if _, statErr := os.Stat(textureFileName); statErr == nil {
+			continue
+		}
+		blockData, readErr := readBlock(textures, chunk.ID(textureID), 0)
+		if readErr == nil {
+			readErr = convert.ToPng(textureFileName, blockData, palette)
+		}
+		if readErr != nil {
+			record.warn("Failed to export texture %v: %v", chunk.ID(textureID), readErr)
+		} else {
+			record.Files = append(record.Files, textureFileName)
+		}
 	}
-	return
 }
 
-func exportVideoClip(provider chunk.Provider, blockData []byte, fileBaseName string, framesPerSecond float32, pal color.Palette) (failed bool) {
+func exportVideoClip(provider chunk.Provider, game *gameInstallation, blockData []byte, fileBaseName string, framesPerSecond float32, pal color.Palette) (files []string, err error) {
+	if len(pal) == 0 {
+		err = convert.ErrPaletteRequired
+		return
+	}
 	reader := bytes.NewReader(blockData)
 	sequence := data.DefaultVideoClipSequence((len(blockData) - data.VideoClipSequenceBaseSize) / data.VideoClipSequenceEntrySize)
-	var err error
 	clipPalette := make([]color.Color, len(pal))
 
 	clipPalette[0] = color.NRGBA{R: 0, G: 0, B: 0, A: 0xFF}
@@ -268,78 +748,369 @@ func exportVideoClip(provider chunk.Provider, blockData []byte, fileBaseName str
 		}
 
 		framesChunk, framesErr := provider.Chunk(chunk.ID(sequence.FramesID))
+		if ((framesErr != nil) || (framesChunk == nil)) && (game != nil) {
+			framesChunk, framesErr = game.findChunk(chunk.ID(sequence.FramesID))
+		}
 
 		if framesErr != nil {
-			fmt.Printf("Failed to access chunk for frames: %v", framesErr)
+			err = fmt.Errorf("failed to access chunk for frames: %v", framesErr)
 			return
 		}
 		imageRect := goImage.Rect(0, 0, int(sequence.Width), int(sequence.Height))
 		img := goImage.NewPaletted(imageRect, clipPalette)
-		handler := newExportingMediaHandler(fileBaseName, mediaDuration, framesPerSecond, 0.0)
+		handler := newExportingMediaHandler(fileBaseName, mediaDuration, framesPerSecond, 0.0, nil)
 		for frameID := 0; frameID < framesChunk.BlockCount() && err == nil; frameID++ {
 			frameReader, frameErr := framesChunk.Block(frameID)
 			var header image.BitmapHeader
 
 			if frameErr != nil {
-				fmt.Printf("Failed to load frame %v.%d: %v\n", chunk.ID(sequence.FramesID), frameID, frameErr)
-			} else {
-				binary.Read(frameReader, binary.LittleEndian, &header)
+				err = fmt.Errorf("failed to load frame %v.%d: %v", chunk.ID(sequence.FramesID), frameID, frameErr)
+			} else if frameID >= len(times) {
+				err = fmt.Errorf("frame %v.%d is not part of the sequence", chunk.ID(sequence.FramesID), frameID)
+			} else if err = binary.Read(frameReader, binary.LittleEndian, &header); err == nil {
 				err = rle.Decompress(frameReader, img.Pix)
 				handler.OnVideo(times[int(frameID)], img)
 			}
 		}
-		handler.finish()
+		if finishErr := handler.finish(); err == nil {
+			err = finishErr
+		}
+		files = handler.files
 	}
 
+	return
+}
+
+// importOptions control how source files are converted on import.
+type importOptions struct {
+	// compressed, if not nil, overrides the compression of imported bitmaps.
+	compressed *bool
+	// transparency, if not nil, overrides the transparency flag of imported bitmaps.
+	transparency *bool
+
+	// palette returns the palette imported images are mapped to, loaded on first use. The palette may be nil.
+	palette func() (color.Palette, error)
+	// dither enables dithering when mapping true-color images to the palette.
+	dither bool
+	// strictPalette rejects paletted images with colors that do not exist in the palette.
+	strictPalette bool
+	// privatePalette stores the palette of imported images with the bitmap.
+	privatePalette bool
+	// anchor, if not nil, overrides the anchor (hotspot) box of imported bitmaps.
+	anchor *[4]uint16
+	// reference, if not nil, provides the source texts translation catalogs are checked against.
+	reference chunk.Provider
+	// codepage is used to encode texts.
+	codepage text.Codepage
+	// textLimits restrict the texts imported into text chunks.
+	textLimits textLimitSet
+}
+
+// importArguments returns the options for converting imported files.
+func importArguments(arguments map[string]interface{}) (options importOptions, err error) {
+	options = importOptions{
+		dither:         arguments["--dither"].(bool),
+		strictPalette:  arguments["--strict-palette"].(bool),
+		privatePalette: arguments["--private-palette"].(bool)}
+	options.compressed = switchArguments(arguments, "--compressed", "--uncompressed")
+	options.transparency = switchArguments(arguments, "--force-transparency", "--no-transparency")
+	if anchorText := arguments["--anchor"]; anchorText != nil {
+		options.anchor, err = parseAnchor(anchorText.(string))
+		if err != nil {
+			return
+		}
+	}
+	options.codepage, err = convert.LoadCodepage(arguments["--codepage"].(string))
 	if err != nil {
-		fmt.Printf("error exporting video clip: %v\n", err)
-		failed = true
+		err = fmt.Errorf("Failed to load code page: %v", err)
+		return
+	}
+	if limitsArgument := arguments["--text-limits"]; limitsArgument != nil {
+		options.textLimits, err = loadTextLimits(limitsArgument.(string))
+		if err != nil {
+			err = fmt.Errorf("Failed to load text limits: %v", err)
+			return
+		}
+	}
+	if referenceArgument := arguments["--reference"]; referenceArgument != nil {
+		options.reference, err = loadResourceFile(referenceArgument.(string))
+		if err != nil {
+			err = fmt.Errorf("Failed to read reference file: %v", err)
+			return
+		}
+	}
+	options.palette = importPaletteArguments(arguments)
+	return
+}
+
+// importPaletteArguments returns a function that loads the palette for imported images on first call, so that
+// a missing or broken palette only affects the import of bitmaps. As on export, the palette of the game is
+// only used if the game directory is given explicitly, not from the configuration file.
+func importPaletteArguments(arguments map[string]interface{}) func() (color.Palette, error) {
+	loaded := false
+	var palette color.Palette
+	var err error
+
+	return func() (color.Palette, error) {
+		if loaded {
+			return palette, err
+		}
+		loaded = true
+		var game *gameInstallation
+		if game, err = explicitGameArguments(arguments); err != nil {
+			return nil, err
+		}
+		palette, _, err = paletteArguments(arguments, game)
+		return palette, err
+	}
+}
+
+// switchArguments returns whether the on or the off flag was given, or nil if neither was.
+func switchArguments(arguments map[string]interface{}, on string, off string) *bool {
+	value := arguments[on].(bool)
+	if !value && !arguments[off].(bool) {
+		return nil
+	}
+	return &value
+}
+
+// parseAnchor parses a box in the form "left,top,right,bottom".
+func parseAnchor(text string) (*[4]uint16, error) {
+	var anchor [4]uint16
+	values := strings.Split(text, ",")
+	if len(values) != len(anchor) {
+		return nil, fmt.Errorf("Invalid anchor <%v>, expected left,top,right,bottom", text)
+	}
+	for index, valueText := range values {
+		value, err := strconv.ParseUint(strings.TrimSpace(valueText), 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid anchor <%v>: %v", text, err)
+		}
+		anchor[index] = uint16(value)
+	}
+	return &anchor, nil
+}
+
+// paletteArguments loads the palette specified by the arguments, or the palette of the game if none was specified.
+// Returns a nil palette and an empty file name if there is neither.
+func paletteArguments(arguments map[string]interface{}, game *gameInstallation) (palette color.Palette, paletteFile string, err error) {
+	palArgument := arguments["--pal"]
+	paletteID := uint64(0)
+
+	if palIDArgument := arguments["--pal-id"]; palIDArgument != nil {
+		paletteID, err = strconv.ParseUint(palIDArgument.(string), 0, 16)
+		if err != nil {
+			return
+		}
+	}
+	if palArgument != nil {
+		paletteFile = palArgument.(string)
+	} else if game != nil {
+		paletteFile = game.file(gamePaletteFileName)
+	}
+	if len(paletteFile) > 0 {
+		palette, err = loadPalette(paletteFile, chunk.ID(uint16(paletteID)))
 	}
 	return
 }
 
-func importData(resourceFile string, chunkID chunk.Identifier, blockID int, sourceFile string,
-	compressed, forceTransparency bool) {
-	inFile, inFileErr := os.Open(resourceFile)
-	if inFileErr != nil {
-		fmt.Printf("Failed to open input file: %v\n", inFileErr)
+// importableContentTypes lists the content types that converted source files can be imported into.
+// Files with other extensions are imported raw, into any content type.
+var importableContentTypes = map[string][]chunk.ContentType{
+	".wav": {chunk.Sound, chunk.Media},
+	".png": {chunk.Bitmap, chunk.Palette},
+	".pal": {chunk.Palette},
+	".gpl": {chunk.Palette},
+	".act": {chunk.Palette},
+	".xml": {chunk.Text},
+	".po":  {chunk.Text},
+	".csv": {chunk.Text}}
+
+// checkImportable returns an error if the source file can not be imported into the given content type.
+func checkImportable(sourceFile string, contentType chunk.ContentType) error {
+	extension := strings.ToLower(path.Ext(sourceFile))
+	contentTypes, converted := importableContentTypes[extension]
+	if !converted {
+		return nil
+	}
+	for _, possibleType := range contentTypes {
+		if possibleType == contentType {
+			return nil
+		}
+	}
+	return fmt.Errorf("%v files can not be imported into %v chunks", extension, contentTypeName(contentType))
+}
+
+// importData replaces the identified block of a resource file with the converted content of the source file.
+func importData(record *blockRecord, resourceFile string, chunkID chunk.Identifier, blockID int, sourceFile string,
+	options importOptions, save saveOptions) {
+	store, loadErr := loadResourceFile(resourceFile)
+	if loadErr != nil {
+		record.fail("Failed to read resources from input file: %v", loadErr)
+		return
+	}
+	if !importBlock(record, store, chunkID, blockID, sourceFile, options) {
 		return
 	}
-	defer inFile.Close()
-	reader, readerErr := resfile.ReaderFrom(inFile)
-	if readerErr != nil {
-		fmt.Printf("Failed to read resources from input file: %v\n", readerErr)
+	err := saveResourceFile(resourceFile, store, save)
+	if err != nil {
+		record.fail("Failed to save file: %v", err)
 		return
 	}
-	store := chunk.NewProviderBackedStore(reader)
+}
 
+// importBlock replaces the identified block within the store with the converted content of the source file.
+// Returns false if the chunk could not be modified.
+func importBlock(record *blockRecord, store *chunk.ProviderBackedStore, chunkID chunk.Identifier, blockID int, sourceFile string,
+	options importOptions) bool {
 	modChunk, chunkErr := store.Chunk(chunkID)
 	if chunkErr != nil {
-		fmt.Printf("Failed to access chunk to modify: %v\n", chunkErr)
-		return
+		record.fail("Failed to access chunk to modify: %v", chunkErr)
+		return false
+	}
+	record.ContentType = contentTypeName(modChunk.ContentType)
+	record.Files = []string{sourceFile}
+	if isTextFile(sourceFile) && (modChunk.ContentType == chunk.Text) {
+		return importText(record, store, chunkID, modChunk, sourceFile, options.textLimits.forChunk(chunkID), options.codepage)
+	}
+	if isTextCatalog(sourceFile) && (modChunk.ContentType == chunk.Text) {
+		return importTextCatalog(record, store, chunkID, modChunk, sourceFile, options.reference,
+			options.textLimits.forChunk(chunkID), options.codepage)
+	}
+	var previous []byte
+	if blockID < modChunk.BlockCount() {
+		if blockReader, blockErr := modChunk.Block(blockID); blockErr == nil {
+			previous, _ = ioutil.ReadAll(blockReader)
+		}
 	}
-	modChunk.SetBlock(blockID, importFile(sourceFile, modChunk.ContentType, compressed, forceTransparency))
+	data := importFile(record, sourceFile, modChunk.ContentType, previous, options)
+	if data == nil {
+		return false
+	}
+	if blockID < modChunk.BlockCount() {
+		record.Change = fmt.Sprintf("%d -> %d bytes", len(previous), len(data))
+	} else {
+		record.Change = fmt.Sprintf("new block with %d bytes", len(data))
+	}
+	modChunk.SetBlock(blockID, data)
+	return true
+}
 
-	buffer := serial.NewByteStore()
-	writeErr := resfile.Write(buffer, store)
-	if writeErr != nil {
-		fmt.Printf("Failed to re-encode data: %v\n", writeErr)
-		return
+// isTextFile returns true if the given source file holds all blocks of a text chunk.
+func isTextFile(sourceFile string) bool {
+	return strings.ToLower(path.Ext(sourceFile)) == ".xml"
+}
+
+// isTextCatalog returns true if the given source file is a translation catalog.
+func isTextCatalog(sourceFile string) bool {
+	extension := strings.ToLower(path.Ext(sourceFile))
+	for _, catalogExtension := range convert.TextCatalogExtensions {
+		if extension == catalogExtension {
+			return true
+		}
 	}
-	err := ioutil.WriteFile(resourceFile, buffer.Data(), os.FileMode(0644))
+	return false
+}
+
+// importTextCatalog merges the translations of the catalog into the given text chunk.
+// Blocks without translation are kept; they, as well as entries that do not fit the chunk, are reported as warnings.
+// As on export, the reference texts are decoded with the default code page of the game.
+func importTextCatalog(record *blockRecord, store *chunk.ProviderBackedStore, chunkID chunk.Identifier, modChunk *chunk.Chunk,
+	sourceFile string, reference chunk.Provider, limits textLimits, cp text.Codepage) bool {
+	record.Converter = strings.TrimPrefix(strings.ToLower(path.Ext(sourceFile)), ".")
+	entries, err := convert.ReadTextCatalog(sourceFile)
 	if err != nil {
-		fmt.Printf("Failed to save file: %v\n", err)
-		return
+		record.fail("Failed to read catalog %v: %v", sourceFile, err)
+		return false
+	}
+	referenceChunk, err := referenceText(reference, chunkID)
+	if err != nil {
+		record.fail("Failed to check catalog %v: %v", sourceFile, err)
+		return false
+	}
+	texts, err := convert.DecodeTexts(modChunk, cp)
+	if err != nil {
+		record.fail("Failed to read texts to merge into: %v", err)
+		return false
+	}
+	var referenceTexts []string
+	if referenceChunk != nil {
+		referenceTexts, err = convert.DecodeTexts(referenceChunk, text.DefaultCodepage())
+		if err != nil {
+			record.fail("Failed to read reference texts: %v", err)
+			return false
+		}
+	}
+	merged, merge := convert.MergeTextCatalog(chunkID.Value(), texts, referenceTexts, entries)
+	if len(merge.Untranslated) > 0 {
+		record.warn("%d untranslated entries kept: blocks %v", len(merge.Untranslated), merge.Untranslated)
+	}
+	if len(merge.Stale) > 0 {
+		record.warn("%d stale entries ignored: %v", len(merge.Stale), strings.Join(merge.Stale, ", "))
+	}
+	if len(merge.Fuzzy) > 0 {
+		record.warn("%d fuzzy entries ignored: %v", len(merge.Fuzzy), strings.Join(merge.Fuzzy, ", "))
+	}
+	blocks := encodeTexts(record, merged, limits, cp)
+	if blocks == nil {
+		return false
+	}
+	record.Change = fmt.Sprintf("%d of %d blocks translated", merge.Translated, len(blocks))
+	store.Put(chunkID, &chunk.Chunk{
+		ContentType:   modChunk.ContentType,
+		Compressed:    modChunk.Compressed,
+		Fragmented:    modChunk.Fragmented,
+		BlockProvider: chunk.MemoryBlockProvider(blocks)})
+	return true
+}
+
+// importText replaces all blocks of the given text chunk with the entries of the source file.
+func importText(record *blockRecord, store *chunk.ProviderBackedStore, chunkID chunk.Identifier, modChunk *chunk.Chunk,
+	sourceFile string, limits textLimits, cp text.Codepage) bool {
+	record.Converter = "xml"
+	texts, err := convert.ReadTxt(sourceFile)
+	if err != nil {
+		record.fail("Failed to read text from %v: %v", sourceFile, err)
+		return false
+	}
+	if !modChunk.Fragmented && (len(texts) != 1) {
+		record.fail("Text of %v has %d entries, but chunk is not fragmented", sourceFile, len(texts))
+		return false
 	}
+	if (modChunk.BlockCount() > 0) && (len(texts) != modChunk.BlockCount()) {
+		record.warn("Text of %v has %d entries, but chunk had %d blocks", sourceFile, len(texts), modChunk.BlockCount())
+	}
+	blocks := encodeTexts(record, texts, limits, cp)
+	if blocks == nil {
+		return false
+	}
+	record.Change = fmt.Sprintf("%d -> %d blocks", modChunk.BlockCount(), len(blocks))
+	store.Put(chunkID, &chunk.Chunk{
+		ContentType:   modChunk.ContentType,
+		Compressed:    modChunk.Compressed,
+		Fragmented:    modChunk.Fragmented,
+		BlockProvider: chunk.MemoryBlockProvider(blocks)})
+	return true
 }
 
-func importFile(sourceFile string, contentType chunk.ContentType, compressed, forceTransparency bool) (data []byte) {
-	extension := path.Ext(sourceFile)
+// importFile converts the given source file into block data for the given content type.
+// previous is the data of the block that is replaced, nil for new blocks.
+// Returns nil if the source file could not be converted; the record then holds the reason.
+func importFile(record *blockRecord, sourceFile string, contentType chunk.ContentType, previous []byte,
+	options importOptions) (data []byte) {
+	if err := checkImportable(sourceFile, contentType); err != nil {
+		record.fail("Cannot import %v: %v", sourceFile, err)
+		return
+	}
+	extension := strings.ToLower(path.Ext(sourceFile))
 	switch extension {
 	case ".wav":
 		{
-			soundData := wav.ImportFromWav(sourceFile)
-			if contentType == chunk.Sound {
+			record.Converter = "wav"
+			soundData, soundErr := wav.ImportFromWav(sourceFile)
+			if soundErr != nil {
+				record.fail("Failed to read sound from %v: %v", sourceFile, soundErr)
+			} else if contentType == chunk.Sound {
 				data = audio.EncodeSoundChunk(soundData)
 			} else if contentType == chunk.Media {
 				data = movi.ContainSoundData(soundData)
@@ -347,21 +1118,41 @@ func importFile(sourceFile string, contentType chunk.ContentType, compressed, fo
 		}
 	case ".png":
 		{
-			if contentType == chunk.Bitmap {
-				data = convert.FromPng(sourceFile, false, compressed, forceTransparency)
+			if contentType == chunk.Palette {
+				record.Converter = "palette"
+				data = importPalette(record, sourceFile)
+			} else {
+				record.Converter = "png"
+				data = importBitmap(record, sourceFile, previous, options)
 			}
 		}
+	case ".pal", ".gpl", ".act":
+		{
+			record.Converter = "palette"
+			data = importPalette(record, sourceFile)
+		}
+	case ".xml":
+		{
+			record.Converter = "xml"
+			record.fail("Text %v holds all blocks of a chunk and can only be imported as a whole", sourceFile)
+		}
+	case ".po", ".csv":
+		{
+			record.Converter = strings.TrimPrefix(extension, ".")
+			record.fail("Catalog %v can only be merged into an existing text chunk", sourceFile)
+		}
 	default:
 		{
 			var dataErr error
+			record.Converter = "raw"
 			data, dataErr = ioutil.ReadFile(sourceFile)
 			if dataErr != nil {
-				fmt.Printf("Failed to read from source file: %v\n", dataErr)
+				record.fail("Failed to read from source file: %v", dataErr)
 			}
 		}
 	}
 	if data == nil {
-		fmt.Printf("No data produced from source file - Is the target chunk compatible with input file?\n")
+		record.fail("No data produced from source file - Is the target chunk compatible with input file?")
 	}
 
 	return